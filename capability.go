@@ -0,0 +1,92 @@
+package golisp
+
+import (
+	"errors"
+	"strings"
+)
+
+type Capability uint
+
+const (
+	IORead Capability = 1 << iota
+	IOWrite
+	Process
+	Console
+
+	Pure            Capability = 0
+	AllCapabilities            = IORead | IOWrite | Process | Console
+)
+
+var capabilityNames = []struct {
+	cap  Capability
+	name string
+}{
+	{IORead, "io-read"},
+	{IOWrite, "io-write"},
+	{Process, "process"},
+	{Console, "console"},
+}
+
+type RestrictedBuiltin interface {
+	BuiltinImpl
+	Capability() Capability
+}
+
+type PermissionDenied struct {
+	Name       string
+	Capability Capability
+}
+
+func (e PermissionDenied) Error() string {
+	return "Permission denied: builtin " + e.Name + " requires " + e.Capability.String() + " capability"
+}
+
+func ParseCapability(s string) (Capability, error) {
+	var caps Capability
+	for _, name := range strings.Split(s, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "pure":
+			continue
+		case "all":
+			caps |= AllCapabilities
+			continue
+		}
+		found := false
+		for _, c := range capabilityNames {
+			if c.name == name {
+				caps |= c.cap
+				found = true
+			}
+		}
+		if !found {
+			return Pure, errors.New("Unknown capability: " + name)
+		}
+	}
+	return caps, nil
+}
+
+func (caps Capability) String() string {
+	var names []string
+	for _, c := range capabilityNames {
+		if caps&c.cap != 0 {
+			names = append(names, c.name)
+		}
+	}
+	if len(names) == 0 {
+		return "pure"
+	}
+	return strings.Join(names, ",")
+}
+
+func (context *Context) builtin(name string) BuiltinImpl {
+	impl, ok := context.Builtins[name]
+	if !ok {
+		panic(EvaluationError{Msg: "Unsupported builtin: " + name})
+	}
+	if r, ok := impl.(RestrictedBuiltin); ok {
+		if required := r.Capability(); required&^context.Capabilities != 0 {
+			panic(PermissionDenied{name, required &^ context.Capabilities})
+		}
+	}
+	return impl
+}
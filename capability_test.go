@@ -0,0 +1,95 @@
+package golisp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCapability(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Capability
+		str  string
+		err  string
+	}{
+		{"pure", Pure, "pure", ""},
+		{"all", AllCapabilities, "io-read,io-write,process,console", ""},
+		{"io-read", IORead, "io-read", ""},
+		{"console, io-read", IORead | Console, "io-read,console", ""},
+		{"pure,process", Process, "process", ""},
+		{"io-write,all", AllCapabilities, "io-read,io-write,process,console", ""},
+		{"network", Pure, "", "Unknown capability: network"},
+		{"io-read,", Pure, "", "Unknown capability: "},
+	}
+	for _, test := range tests {
+		got, err := ParseCapability(test.src)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("ParseCapability(%q): expected error %q but got %v", test.src, test.err, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want || got.String() != test.str {
+			t.Errorf("ParseCapability(%q) = %s, %v, expected %s", test.src, got, err, test.str)
+		}
+	}
+}
+
+type writeBuiltin struct{}
+
+func (writeBuiltin) Run(state *State, args []Value) {
+	state.Push(Nil{})
+}
+
+func (writeBuiltin) Capability() Capability {
+	return IOWrite
+}
+
+func TestPermissionDeniedPosition(t *testing.T) {
+	context := NewContext()
+	context.Builtins["write"] = writeBuiltin{}
+	context.Capabilities = Pure
+	_, err := evalString(context, "test", "(def f (fun ()\n  (builtin write)))\n(f)")
+	var e PermissionDenied
+	if !errors.As(err, &e) || e.Name != "write" || err.Error() != "test:2:4: Evaluation error: Permission denied: builtin write requires io-write capability" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestRestrictedBuiltin(t *testing.T) {
+	tests := []struct {
+		caps Capability
+		want string
+	}{
+		{AllCapabilities, "()"},
+		{IOWrite, "()"},
+		{IORead | Console, "error: 1:3: Evaluation error: Permission denied: builtin write requires io-write capability"},
+		{Pure, "error: 1:3: Evaluation error: Permission denied: builtin write requires io-write capability"},
+	}
+	for _, test := range tests {
+		context := NewContext()
+		context.Builtins["write"] = writeBuiltin{}
+		context.Capabilities = test.caps
+		result, err := evalString(context, "", "((builtin write))")
+		var got string
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = result.Inspect()
+		}
+		if got != test.want {
+			t.Errorf("with %s: expected %s but got %s", test.caps, test.want, got)
+		}
+	}
+
+	// The check is done when the builtin is loaded, not when it is called
+	context := NewContext()
+	context.Builtins["write"] = writeBuiltin{}
+	if _, err := evalString(context, "", "(def write (builtin write))"); err != nil {
+		t.Fatal(err)
+	}
+	context.Capabilities = Pure
+	if _, err := evalString(context, "", "(write)"); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
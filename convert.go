@@ -0,0 +1,517 @@
+package golisp
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	valueType  = reflect.TypeOf((*Value)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+	bigRatType = reflect.TypeOf((*big.Rat)(nil))
+)
+
+func ToValue(v interface{}) (Value, error) {
+	return toValue(reflect.ValueOf(v))
+}
+
+func FromValue(v Value, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("FromValue: destination must be a non-nil pointer")
+	}
+	elem, err := fromValue(v, rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(elem)
+	return nil
+}
+
+type structField struct {
+	name      string
+	index     []int
+	omitempty bool
+	vec       bool
+}
+
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		f := structField{name: field.Name, index: field.Index}
+		if tag, ok := field.Tag.Lookup("lisp"); ok {
+			if tag == "-" {
+				continue
+			}
+			opts := strings.Split(tag, ",")
+			if opts[0] != "" {
+				f.name = opts[0]
+			}
+			for _, opt := range opts[1:] {
+				switch opt {
+				case "omitempty":
+					f.omitempty = true
+				case "vec":
+					f.vec = true
+				}
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func expected(desc string, v Value) error {
+	return errors.New("Expected " + desc + " but got " + v.Inspect())
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Slice, reflect.Array:
+		return "list or vector"
+	case reflect.Map, reflect.Struct:
+		return "association list"
+	case reflect.Ptr:
+		return describeType(t.Elem())
+	default:
+		return t.String()
+	}
+}
+
+func fromValue(v Value, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		rv := reflect.New(t).Elem()
+		if g := goValue(v); g != nil {
+			rv.Set(reflect.ValueOf(g))
+		}
+		return rv, nil
+	}
+	if t.Kind() == reflect.Interface && reflect.TypeOf(v).Implements(t) {
+		rv := reflect.New(t).Elem()
+		rv.Set(reflect.ValueOf(v))
+		return rv, nil
+	}
+
+	rv := reflect.New(t).Elem()
+	if t.Implements(valueType) {
+		if reflect.TypeOf(v) != t {
+			return rv, expected(strings.ToLower(t.Name()), v)
+		}
+		rv.Set(reflect.ValueOf(v))
+		return rv, nil
+	}
+	if t == bigIntType {
+		i, ok := exactInt(v)
+		if !ok {
+			return rv, expected("integer", v)
+		}
+		rv.Set(reflect.ValueOf(i))
+		return rv, nil
+	}
+	if t == bigRatType {
+		r, ok := ToRat(v)
+		if !ok {
+			return rv, expected("exact number", v)
+		}
+		rv.Set(reflect.ValueOf(r))
+		return rv, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := exactInt(v)
+		if !ok || !i.IsInt64() || rv.OverflowInt(i.Int64()) {
+			return rv, expected(describeType(t), v)
+		}
+		rv.SetInt(i.Int64())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := exactInt(v)
+		if !ok || !i.IsUint64() || rv.OverflowUint(i.Uint64()) {
+			return rv, expected(describeType(t), v)
+		}
+		rv.SetUint(i.Uint64())
+
+	case reflect.Float32, reflect.Float64:
+		num, ok := ToFloat(v)
+		if !ok {
+			return rv, expected(describeType(t), v)
+		}
+		rv.SetFloat(num)
+
+	case reflect.String:
+		str, ok := v.(Str)
+		if !ok {
+			return rv, expected(describeType(t), v)
+		}
+		rv.SetString(str.Data)
+
+	case reflect.Bool:
+		b, ok := v.(Bool)
+		if !ok {
+			return rv, expected(describeType(t), v)
+		}
+		rv.SetBool(b.Data)
+
+	case reflect.Slice, reflect.Array:
+		if b, ok := v.(Bytes); ok && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			rv.Set(reflect.ValueOf(append([]byte(nil), b.Data...)).Convert(t))
+			return rv, nil
+		}
+		items, ok := Slice(v)
+		if vec, isVec := v.(Vec); isVec {
+			items, ok = vec.Payload, true
+		}
+		if !ok || (t.Kind() == reflect.Array && len(items) != t.Len()) {
+			return rv, expected(describeType(t), v)
+		}
+		if t.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(t, len(items), len(items)))
+		}
+		for i, item := range items {
+			elem, err := fromValue(item, t.Elem())
+			if err != nil {
+				return rv, err
+			}
+			rv.Index(i).Set(elem)
+		}
+
+	case reflect.Map:
+		entries, ok := alist(v)
+		if table, isTable := v.(HashTable); isTable {
+			entries, ok = table.Entries(), true
+		}
+		if !ok {
+			return rv, expected(describeType(t), v)
+		}
+		rv.Set(reflect.MakeMapWithSize(t, len(entries)))
+		for _, entry := range entries {
+			// Symbol keys are accepted for string keys as well as field names of structs
+			if sym, ok := entry.Car.(Sym); ok && t.Key().Kind() == reflect.String {
+				entry.Car = Str{sym.Data}
+			}
+			key, err := fromValue(entry.Car, t.Key())
+			if err != nil {
+				return rv, err
+			}
+			elem, err := fromValue(entry.Cdr, t.Elem())
+			if err != nil {
+				return rv, err
+			}
+			rv.SetMapIndex(key, elem)
+		}
+
+	case reflect.Struct:
+		entries, ok := alist(v)
+		if r, isRecord := v.(Record); isRecord {
+			entries, ok = r.entries(), true
+		}
+		if !ok {
+			return rv, expected(describeType(t), v)
+		}
+		fields := structFields(t)
+		for _, entry := range entries {
+			var name string
+			switch key := entry.Car.(type) {
+			case Sym:
+				name = key.Data
+			case Str:
+				name = key.Data
+			default:
+				return rv, expected("field name", entry.Car)
+			}
+			for _, field := range fields {
+				if field.name != name {
+					continue
+				}
+				elem, err := fromValue(entry.Cdr, t.FieldByIndex(field.index).Type)
+				if err != nil {
+					return rv, errors.New(name + ": " + err.Error())
+				}
+				rv.FieldByIndex(field.index).Set(elem)
+			}
+		}
+
+	case reflect.Ptr:
+		if _, ok := v.(Nil); ok {
+			return rv, nil
+		}
+		elem, err := fromValue(v, t.Elem())
+		if err != nil {
+			return rv, err
+		}
+		rv.Set(reflect.New(t.Elem()))
+		rv.Elem().Set(elem)
+
+	default:
+		return rv, errors.New("Unsupported Go type: " + t.String())
+	}
+	return rv, nil
+}
+
+// Values decoded into interface{} become plain Go values as encoding/json does: int64 (or *big.Int),
+// *big.Rat, float64, string for strings, symbols and characters, bool, []byte, []interface{} for
+// lists and vectors, map[string]interface{} for association lists, hash tables and records whose keys
+// are strings or symbols, and nil for (). Other values such as procedures are kept as they are.
+func goValue(v Value) interface{} {
+	switch v := v.(type) {
+	case Int:
+		if v.Big != nil {
+			return new(big.Int).Set(v.Big)
+		}
+		return v.Data
+	case Rat:
+		return new(big.Rat).Set(v.Data)
+	case Num:
+		return v.Data
+	case Str:
+		return v.Data
+	case Sym:
+		return v.Data
+	case Char:
+		return string(v.Data)
+	case Bool:
+		return v.Data
+	case Nil:
+		return nil
+	case Bytes:
+		return append([]byte(nil), v.Data...)
+	case Vec:
+		return goSlice(v.Payload)
+	case Cons:
+		if entries, ok := alist(v); ok {
+			if m, ok := goMap(entries); ok {
+				return m
+			}
+		}
+		if items, ok := Slice(v); ok {
+			return goSlice(items)
+		}
+	case HashTable:
+		if m, ok := goMap(v.Entries()); ok {
+			return m
+		}
+	case Record:
+		if m, ok := goMap(v.entries()); ok {
+			return m
+		}
+	}
+	return v
+}
+
+func goSlice(items []Value) []interface{} {
+	s := make([]interface{}, len(items))
+	for i, item := range items {
+		s[i] = goValue(item)
+	}
+	return s
+}
+
+func goMap(entries []Cons) (map[string]interface{}, bool) {
+	m := make(map[string]interface{}, len(entries))
+	for _, entry := range entries {
+		switch key := entry.Car.(type) {
+		case Str:
+			m[key.Data] = goValue(entry.Cdr)
+		case Sym:
+			m[key.Data] = goValue(entry.Cdr)
+		default:
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+func toValue(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return Nil{}, nil
+	}
+	if rv.Type().Implements(valueType) {
+		if rv.Kind() == reflect.Interface && rv.IsNil() {
+			return Nil{}, nil
+		}
+		return rv.Interface().(Value), nil
+	}
+
+	if rv.Type() == bigIntType {
+		if rv.IsNil() {
+			return Nil{}, nil
+		}
+		return MakeInt(new(big.Int).Set(rv.Interface().(*big.Int))), nil
+	}
+	if rv.Type() == bigRatType {
+		if rv.IsNil() {
+			return Nil{}, nil
+		}
+		return MakeRat(new(big.Rat).Set(rv.Interface().(*big.Rat))), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int{Data: rv.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return MakeInt(new(big.Int).SetUint64(rv.Uint())), nil
+
+	case reflect.Float32, reflect.Float64:
+		return Num{rv.Float()}, nil
+
+	case reflect.String:
+		return Str{rv.String()}, nil
+
+	case reflect.Bool:
+		return Bool{rv.Bool()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return Bytes{Data: append([]byte(nil), rv.Bytes()...)}, nil
+		}
+		items := make([]Value, rv.Len())
+		for i := range items {
+			item, err := toValue(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return List(items...), nil
+
+	case reflect.Map:
+		entries := make([]Value, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := toValue(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			elem, err := toValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, Cons{Car: key, Cdr: elem})
+		}
+		sortEntries(entries)
+		return List(entries...), nil
+
+	case reflect.Struct:
+		var entries []Value
+		for _, field := range structFields(rv.Type()) {
+			f := rv.FieldByIndex(field.index)
+			if field.omitempty && f.IsZero() {
+				continue
+			}
+			elem, err := toValue(f)
+			if err != nil {
+				return nil, errors.New(field.name + ": " + err.Error())
+			}
+			if items, ok := Slice(elem); ok && field.vec {
+				elem = Vec{items}
+			}
+			entries = append(entries, Cons{Car: Sym{field.name}, Cdr: elem})
+		}
+		return List(entries...), nil
+
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return Nil{}, nil
+		}
+		return toValue(rv.Elem())
+
+	default:
+		return nil, errors.New("Unsupported Go type: " + rv.Type().String())
+	}
+}
+
+// Entries of a map are ordered by their keys, numerically for numbers and by the printed form otherwise.
+// The printed forms are computed once since printing can be costly.
+type byKey struct {
+	entries []Value
+	printed []string
+}
+
+func sortEntries(entries []Value) {
+	printed := make([]string, len(entries))
+	for i, entry := range entries {
+		if key := entry.(Cons).Car; !IsNumber(key) {
+			printed[i] = key.Inspect()
+		}
+	}
+	sort.Sort(byKey{entries, printed})
+}
+
+func (s byKey) Len() int {
+	return len(s.entries)
+}
+
+func (s byKey) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+	s.printed[i], s.printed[j] = s.printed[j], s.printed[i]
+}
+
+func (s byKey) Less(i, j int) bool {
+	a, b := s.entries[i].(Cons).Car, s.entries[j].(Cons).Car
+	if IsNumber(a) && IsNumber(b) {
+		return lessNum(a, b)
+	}
+	return s.printed[i] < s.printed[j]
+}
+
+func lessNum(a, b Value) bool {
+	if x, ok := ToRat(a); ok {
+		if y, ok := ToRat(b); ok {
+			return x.Cmp(y) < 0
+		}
+	}
+	x, _ := ToFloat(a)
+	y, _ := ToFloat(b)
+	return x < y
+}
+
+func exactInt(v Value) (*big.Int, bool) {
+	switch v := v.(type) {
+	case Int:
+		return v.ToBig(), true
+	case Num:
+		if math.IsInf(v.Data, 0) || v.Data != math.Trunc(v.Data) {
+			return nil, false
+		}
+		i, _ := big.NewFloat(v.Data).Int(nil)
+		return i, true
+	default:
+		return nil, false
+	}
+}
+
+func alist(v Value) ([]Cons, bool) {
+	items, ok := Slice(v)
+	if !ok {
+		return nil, false
+	}
+	entries := make([]Cons, len(items))
+	for i, item := range items {
+		entry, ok := item.(Cons)
+		if !ok {
+			return nil, false
+		}
+		entries[i] = entry
+	}
+	return entries, true
+}
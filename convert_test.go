@@ -0,0 +1,239 @@
+package golisp
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+type point struct {
+	X       int    `lisp:"x"`
+	Y       int    `lisp:"y"`
+	Label   string `lisp:"label,omitempty"`
+	Tags    []string
+	Path    []int `lisp:"path,vec"`
+	Ignored bool  `lisp:"-"`
+	hidden  int
+}
+
+func pair(car, cdr Value) Value {
+	return Cons{Car: car, Cdr: cdr}
+}
+
+func TestToValue(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, "()"},
+		{42, "42"},
+		{uint64(1 << 63), "9223372036854775808"},
+		{1.5, "1.5"},
+		{"s", `"s"`},
+		{true, "#t"},
+		{[]int{1, 2}, "(1 2)"},
+		{[2]string{"a", "b"}, `("a" "b")`},
+		{[]byte{1, 2}, "#u8(1 2)"},
+		{big.NewRat(1, 3), "1/3"},
+		{map[string]int{"b": 2, "a": 1}, `(("a" . 1) ("b" . 2))`},
+		{map[int]bool{10: true, 9: false, -1: true}, "((-1 . #t) (9 . #f) (10 . #t))"},
+		{map[float64]int{2.5: 1, 10: 2}, "((2.5 . 1) (10.0 . 2))"},
+		{point{X: 1, Y: 2, Path: []int{3}}, "((x . 1) (y . 2) (Tags) (path . #(3)))"},
+		{&point{Label: "p"}, `((x . 0) (y . 0) (label . "p") (Tags) (path . #()))`},
+		{Sym{"v"}, "v"},
+	}
+	for _, test := range tests {
+		v, err := ToValue(test.in)
+		if err != nil {
+			t.Errorf("ToValue(%#v): %v", test.in, err)
+		} else if got := v.Inspect(); got != test.want {
+			t.Errorf("ToValue(%#v) = %s, want %s", test.in, got, test.want)
+		}
+	}
+
+	if _, err := ToValue(make(chan int)); err == nil {
+		t.Errorf("ToValue(chan) succeeded")
+	}
+}
+
+func TestFromValue(t *testing.T) {
+	var p point
+	src := List(pair(Sym{"x"}, Int{Data: 1}), pair(Str{"y"}, Num{2}), pair(Sym{"Tags"}, List(Str{"a"})), pair(Sym{"path"}, Vec{[]Value{Int{Data: 5}}}))
+	if err := FromValue(src, &p); err != nil {
+		t.Fatal(err)
+	}
+	if want := (point{X: 1, Y: 2, Tags: []string{"a"}, Path: []int{5}}); !reflect.DeepEqual(p, want) {
+		t.Errorf("got %#v, want %#v", p, want)
+	}
+
+	var q point
+	r, _ := NewRecordType("point", "x", "y").New(Int{Data: 3}, Int{Data: 4})
+	if err := FromValue(r, &q); err != nil || q.X != 3 || q.Y != 4 {
+		t.Errorf("got %#v, %v", q, err)
+	}
+
+	h := NewHashTable()
+	h.Set(Str{"a"}, Int{Data: 1})
+	var m map[string]int
+	if err := FromValue(h, &m); err != nil || !reflect.DeepEqual(m, map[string]int{"a": 1}) {
+		t.Errorf("got %#v, %v", m, err)
+	}
+
+	var b []byte
+	if err := FromValue(Bytes{[]byte{7}}, &b); err != nil || !reflect.DeepEqual(b, []byte{7}) {
+		t.Errorf("got %#v, %v", b, err)
+	}
+
+	var ptr *int
+	if err := FromValue(Nil{}, &ptr); err != nil || ptr != nil {
+		t.Errorf("got %#v, %v", ptr, err)
+	}
+
+	var v Value
+	if err := FromValue(Sym{"s"}, &v); err != nil || !Equal(v, Sym{"s"}) {
+		t.Errorf("got %#v, %v", v, err)
+	}
+
+	var c Cons
+	if err := FromValue(List(Sym{"a"}), &c); err != nil || !Equal(c, List(Sym{"a"})) {
+		t.Errorf("got %#v, %v", c, err)
+	}
+
+	errorTests := []struct {
+		v    Value
+		dest interface{}
+		want string
+	}{
+		{Str{"1"}, new(int), `Expected integer but got "1"`},
+		{Int{Data: 256}, new(uint8), "Expected non-negative integer but got 256"},
+		{Num{1.5}, new(int), "Expected integer but got 1.5"},
+		{List(Int{Data: 1}), new([2]int), "Expected list or vector but got (1)"},
+		{List(pair(Sym{"x"}, Str{"a"})), new(point), `x: Expected integer but got "a"`},
+		{Nil{}, new(Cons), "Expected cons but got ()"},
+		{Str{"s"}, new(Sym), `Expected sym but got "s"`},
+	}
+	for _, test := range errorTests {
+		err := FromValue(test.v, test.dest)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("FromValue(%s): got %v, want %s", test.v.Inspect(), err, test.want)
+		}
+	}
+	if err := FromValue(Nil{}, p); err == nil {
+		t.Errorf("FromValue into a non-pointer succeeded")
+	}
+}
+
+func TestFromValueInterface(t *testing.T) {
+	h := NewHashTable()
+	h.Set(Sym{"k"}, Bool{true})
+	f := fun{}
+	tests := []struct {
+		v    Value
+		want interface{}
+	}{
+		{Int{Data: 1}, int64(1)},
+		{Num{1.5}, 1.5},
+		{Str{"s"}, "s"},
+		{Sym{"s"}, "s"},
+		{Char{'λ'}, "λ"},
+		{Bool{true}, true},
+		{Nil{}, nil},
+		{List(Int{Data: 1}, Str{"a"}), []interface{}{int64(1), "a"}},
+		{Vec{[]Value{Num{2}}}, []interface{}{2.0}},
+		{List(pair(Sym{"a"}, Int{Data: 1}), pair(Str{"b"}, List(Nil{}))), map[string]interface{}{"a": int64(1), "b": []interface{}{nil}}},
+		{h, map[string]interface{}{"k": true}},
+		{pair(Int{Data: 1}, Int{Data: 2}), pair(Int{Data: 1}, Int{Data: 2})},
+		{f, f},
+	}
+	for _, test := range tests {
+		var got interface{}
+		if err := FromValue(test.v, &got); err != nil {
+			t.Errorf("FromValue(%s): %v", test.v.Inspect(), err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("FromValue(%s) = %#v, want %#v", test.v.Inspect(), got, test.want)
+		}
+	}
+
+	var m map[string]any
+	src := List(pair(Sym{"n"}, MakeInt(new(big.Int).Lsh(big.NewInt(1), 70))), pair(Sym{"r"}, MakeRat(big.NewRat(1, 3))))
+	if err := FromValue(src, &m); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := m["n"].(*big.Int); !ok || n.BitLen() != 71 {
+		t.Errorf("n = %#v", m["n"])
+	}
+	if r, ok := m["r"].(*big.Rat); !ok || r.RatString() != "1/3" {
+		t.Errorf("r = %#v", m["r"])
+	}
+}
+
+func TestRecord(t *testing.T) {
+	pointType := NewRecordType("point", "x", "y", "label", "path")
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{point{X: 1, Y: 2}, `#<point x=1 y=2 label="" path=#()>`},
+		{&point{Label: "p", Path: []int{3, 4}}, `#<point x=0 y=0 label="p" path=#(3 4)>`},
+		{struct {
+			Y int `lisp:"y"`
+			Z int `lisp:"z"`
+		}{Y: 5, Z: 6}, `#<point x=() y=5 label=() path=()>`},
+		{1, "error: FromStruct: int is not a struct"},
+		{struct {
+			X chan int `lisp:"x"`
+		}{}, "error: x: Unsupported Go type: chan int"},
+	}
+	for _, test := range tests {
+		r, err := pointType.FromStruct(test.in)
+		var got string
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = r.Inspect()
+		}
+		if got != test.want {
+			t.Errorf("FromStruct(%#v) = %s, want %s", test.in, got, test.want)
+		}
+	}
+
+	r, err := pointType.New(Int{Data: 1}, Int{Data: 2}, Str{"l"}, Vec{[]Value{Int{Data: 3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p point
+	if err := FromValue(r, &p); err != nil || !reflect.DeepEqual(p, point{X: 1, Y: 2, Label: "l", Path: []int{3}}) {
+		t.Errorf("FromValue(%s) = %#v, %v", r.Inspect(), p, err)
+	}
+	var m interface{}
+	if err := FromValue(r, &m); err != nil || !reflect.DeepEqual(m, map[string]interface{}{"x": int64(1), "y": int64(2), "label": "l", "path": []interface{}{int64(3)}}) {
+		t.Errorf("FromValue(%s) = %#v, %v", r.Inspect(), m, err)
+	}
+	if _, err := pointType.New(Int{Data: 1}); err == nil || err.Error() != "point takes 4 fields" {
+		t.Errorf("New with 1 field: %v", err)
+	}
+
+	context := NewContext()
+	context.DefRecord(pointType)
+	origin, err := pointType.FromStruct(point{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	context.RegisterFunc("origin", func() Value { return origin })
+	evalTests := []struct {
+		src, want string
+	}{
+		{"(point? ((builtin origin)))", "#t"},
+		{"(point-label (make-point 1 2 3 4))", "3"},
+		{"(def p ((builtin origin))) (set-point-x! p 5) p", `#<point x=5 y=0 label="" path=#()>`},
+		{"point", "<record-type point>"},
+	}
+	for _, test := range evalTests {
+		result, err := evalString(context, "", test.src)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+		} else if got := result.Inspect(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package golisp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"reflect"
+)
+
+func Equal(a, b Value) bool {
+	return equal(a, b, map[[2]uintptr]bool{})
+}
+
+// A pair of containers compared again is assumed to be equal, so that Equal is co-inductive:
+// cyclic values are equal when their infinite unfoldings are, wherever the cycles are closed.
+// An assumption can be kept after the comparison fails, since then Equal fails as a whole.
+func equalWithin(assumed map[[2]uintptr]bool, a, b interface{}, f func() bool) bool {
+	pair := [2]uintptr{reflect.ValueOf(a).Pointer(), reflect.ValueOf(b).Pointer()}
+	if assumed[pair] {
+		return true
+	}
+	assumed[pair] = true
+	return f()
+}
+
+func equal(a, b Value, assumed map[[2]uintptr]bool) bool {
+	switch a := a.(type) {
+	case Num:
+		b, ok := b.(Num)
+		return ok && (a.Data == b.Data || math.IsNaN(a.Data) && math.IsNaN(b.Data))
+	case Int:
+		b, ok := b.(Int)
+		return ok && a.ToBig().Cmp(b.ToBig()) == 0
+	case Rat:
+		b, ok := b.(Rat)
+		return ok && a.Data.Cmp(b.Data) == 0
+	case Sym:
+		b, ok := b.(Sym)
+		return ok && a.Data == b.Data
+	case Str:
+		b, ok := b.(Str)
+		return ok && a.Data == b.Data
+	case Char:
+		b, ok := b.(Char)
+		return ok && a.Data == b.Data
+	case Bool:
+		b, ok := b.(Bool)
+		return ok && a.Data == b.Data
+	case Nil:
+		_, ok := b.(Nil)
+		return ok
+	case Bytes:
+		b, ok := b.(Bytes)
+		return ok && bytes.Equal(a.Data, b.Data)
+	case Cons:
+		b, ok := b.(Cons)
+		return ok && equal(a.Car, b.Car, assumed) && equal(a.Cdr, b.Cdr, assumed)
+	case Vec:
+		b, ok := b.(Vec)
+		if !ok || len(a.Payload) != len(b.Payload) {
+			return false
+		}
+		if len(a.Payload) == 0 {
+			return true
+		}
+		return equalWithin(assumed, a.Payload, b.Payload, func() bool {
+			for i := range a.Payload {
+				if !equal(a.Payload[i], b.Payload[i], assumed) {
+					return false
+				}
+			}
+			return true
+		})
+	case Record:
+		b, ok := b.(Record)
+		if !ok || a.Type != b.Type {
+			return false
+		}
+		if len(a.Fields) == 0 {
+			return true
+		}
+		return equalWithin(assumed, a.Fields, b.Fields, func() bool {
+			for i := range a.Fields {
+				if !equal(a.Fields[i], b.Fields[i], assumed) {
+					return false
+				}
+			}
+			return true
+		})
+	case HashTable:
+		b, ok := b.(HashTable)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		return equalWithin(assumed, a.table, b.table, func() bool {
+			for _, entry := range a.Entries() {
+				value, ok := b.Get(entry.Car)
+				if !ok || !equal(entry.Cdr, value, assumed) {
+					return false
+				}
+			}
+			return true
+		})
+	default:
+		return false
+	}
+}
+
+// Hash is a function of the unfolding of a value, as Equal is: it walks the value depth-first
+// and stops at the containers after hashBudget of them are entered, writing their lengths only.
+// The entries of a hash table are hashed without entering containers since they are unordered.
+const hashBudget = 32
+
+func Hash(v Value) uint64 {
+	budget := hashBudget
+	return hash(v, &budget)
+}
+
+func hash(v Value, budget *int) uint64 {
+	h := fnv.New64a()
+	writeHash := func(v Value) {
+		h.Write(binary.LittleEndian.AppendUint64(nil, hash(v, budget)))
+	}
+	enter := func(tag byte, n int) bool {
+		h.Write(binary.LittleEndian.AppendUint64([]byte{tag}, uint64(n)))
+		if *budget == 0 {
+			return false
+		}
+		*budget--
+		return true
+	}
+
+	switch v := v.(type) {
+	case Num:
+		data := v.Data
+		if data == 0 {
+			data = 0
+		} else if math.IsNaN(data) {
+			data = math.NaN()
+		}
+		h.Write(binary.LittleEndian.AppendUint64([]byte{'n'}, math.Float64bits(data)))
+	case Int, Rat:
+		h.Write([]byte{'i'})
+		h.Write([]byte(v.Inspect()))
+	case Sym:
+		h.Write([]byte{'y'})
+		h.Write([]byte(v.Data))
+	case Str:
+		h.Write([]byte{'s'})
+		h.Write([]byte(v.Data))
+	case Char:
+		h.Write(binary.LittleEndian.AppendUint32([]byte{'c'}, uint32(v.Data)))
+	case Bool:
+		if v.Data {
+			h.Write([]byte{'t'})
+		} else {
+			h.Write([]byte{'f'})
+		}
+	case Nil:
+		h.Write([]byte{'('})
+	case Bytes:
+		h.Write([]byte{'u'})
+		h.Write(v.Data)
+	case Cons:
+		h.Write([]byte{'.'})
+		var tail Value = v
+		for cons, ok := v, true; ok; cons, ok = tail.(Cons) {
+			writeHash(cons.Car)
+			tail = cons.Cdr
+		}
+		writeHash(tail)
+	case Vec:
+		if enter('v', len(v.Payload)) {
+			for _, item := range v.Payload {
+				writeHash(item)
+			}
+		}
+	case Record:
+		h.Write([]byte(v.Type.Name))
+		if enter('r', len(v.Fields)) {
+			for _, field := range v.Fields {
+				writeHash(field)
+			}
+		}
+	case HashTable:
+		if enter('h', v.Len()) {
+			// Entries are combined independently of their order
+			var sum uint64
+			for _, entry := range v.Entries() {
+				var shallow int
+				sum += hash(entry.Car, &shallow)*31 + hash(entry.Cdr, &shallow)
+			}
+			h.Write(binary.LittleEndian.AppendUint64(nil, sum))
+		}
+	default:
+		h.Write([]byte(reflect.TypeOf(v).String()))
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,85 @@
+package golisp
+
+import (
+	"math"
+	"testing"
+)
+
+func selfReferencingVec(n int) Vec {
+	v := Vec{Payload: make([]Value, n)}
+	for i := range v.Payload {
+		v.Payload[i] = v
+	}
+	return v
+}
+
+func TestEqualAndHash(t *testing.T) {
+	h := NewHashTable()
+	h.Set(Sym{"a"}, Num{1})
+	h1, h2 := NewHashTable(), NewHashTable()
+	h1.Set(Vec{[]Value{Int{Data: 1}}}, Vec{[]Value{Int{Data: 2}}})
+	h1.Set(Sym{"a"}, List(Vec{}))
+	h2.Set(Sym{"a"}, List(Vec{}))
+	h2.Set(Vec{[]Value{Int{Data: 1}}}, Vec{[]Value{Int{Data: 2}}})
+	tests := []struct {
+		a, b  Value
+		equal bool
+	}{
+		{Num{math.NaN()}, Num{math.NaN()}, true},
+		{Num{0}, Num{math.Copysign(0, -1)}, true},
+		{Num{1}, Int{Data: 1}, false},
+		{Int{Data: 1}, Int{Data: 1}, true},
+		{List(Sym{"a"}, Str{"b"}), List(Sym{"a"}, Str{"b"}), true},
+		{List(Sym{"a"}, Str{"b"}), List(Sym{"a"}, Sym{"b"}), false},
+		{Vec{[]Value{Char{'a'}}}, Vec{[]Value{Char{'a'}}}, true},
+		{Bytes{[]byte{1, 2}}, Bytes{[]byte{1, 2}}, true},
+		{Vec{}, Vec{Payload: []Value{}}, true},
+		{h, h, true},
+		{h1, h2, true},
+		{h, h1, false},
+		{selfReferencingVec(12), selfReferencingVec(12), true},
+		{selfReferencingVec(12), selfReferencingVec(11), false},
+	}
+	for _, test := range tests {
+		if got := Equal(test.a, test.b); got != test.equal {
+			t.Errorf("Equal(%s, %s) = %v", test.a.Inspect(), test.b.Inspect(), got)
+		}
+		if test.equal && Hash(test.a) != Hash(test.b) {
+			t.Errorf("Hash(%s) != Hash(%s)", test.a.Inspect(), test.b.Inspect())
+		}
+	}
+}
+
+func TestHashCyclicValues(t *testing.T) {
+	// Both are equal to #(#(#(...))) but close the cycle at different depths
+	a := Vec{Payload: make([]Value, 1)}
+	a.Payload[0] = a
+	b := Vec{Payload: make([]Value, 1)}
+	b.Payload[0] = Vec{Payload: []Value{b}}
+	if !Equal(a, b) || !Equal(b, a) {
+		t.Errorf("cyclic values with the same unfolding are not equal")
+	}
+	if Hash(a) != Hash(b) {
+		t.Errorf("Hash(%s) != Hash(%s)", a.Inspect(), b.Inspect())
+	}
+	c := Vec{Payload: make([]Value, 2)}
+	c.Payload[0], c.Payload[1] = Int{Data: 1}, c
+	d := Vec{Payload: []Value{Int{Data: 1}, Vec{Payload: []Value{Int{Data: 1}, c}}}}
+	e := Vec{Payload: []Value{Int{Data: 1}, Vec{Payload: []Value{Int{Data: 2}, c}}}}
+	if !Equal(c, d) || Hash(c) != Hash(d) {
+		t.Errorf("%s and %s are not equal", c.Inspect(), d.Inspect())
+	}
+	if Equal(c, e) {
+		t.Errorf("%s and %s are equal", c.Inspect(), e.Inspect())
+	}
+
+	h := NewHashTable()
+	h.Set(selfReferencingVec(12), Num{1})
+	h.Set(Sym{"self"}, h)
+	if v, ok := h.Get(selfReferencingVec(12)); !ok || !Equal(v, Num{1}) {
+		t.Errorf("Get(self-referencing vec) = %v, %v", v, ok)
+	}
+	if !Equal(h, h) || Hash(h) != Hash(h) {
+		t.Errorf("a hash table containing itself is not equal to itself")
+	}
+}
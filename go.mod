@@ -4,4 +4,4 @@ go 1.22
 
 toolchain go1.22.3
 
-require golang.org/x/tools v0.21.0
+require golang.org/x/text v0.15.0
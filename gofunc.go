@@ -0,0 +1,86 @@
+package golisp
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+type goFunc struct {
+	name string
+	fn   reflect.Value
+}
+
+func (context *Context) RegisterFunc(name string, fn interface{}) {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func {
+		panic(errors.New("RegisterFunc: " + name + " is not a function"))
+	}
+	context.Builtins[name] = goFunc{name, rv}
+}
+
+func (f goFunc) Run(state *State, args []Value) {
+	t := f.fn.Type()
+	fixed := t.NumIn()
+	if t.IsVariadic() {
+		fixed--
+	}
+	if len(args) < fixed || (len(args) > fixed && !t.IsVariadic()) {
+		var prefix string
+		if t.IsVariadic() {
+			prefix = "at least "
+		}
+		panic(EvaluationError{Msg: f.name + " takes " + prefix + strconv.Itoa(fixed) + " arguments"})
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var argType reflect.Type
+		if i < fixed {
+			argType = t.In(i)
+		} else {
+			argType = t.In(fixed).Elem()
+		}
+		v, err := fromValue(arg, argType)
+		if err != nil {
+			panic(EvaluationError{Msg: f.name + ": argument " + strconv.Itoa(i+1) + ": " + err.Error()})
+		}
+		in[i] = v
+	}
+
+	out := f.fn.Call(in)
+
+	if n := len(out); n > 0 && t.Out(n-1) == errorType {
+		err := out[n-1].Interface()
+		out = out[:n-1]
+		if err != nil {
+			state.Push(Cons{Car: Bool{false}, Cdr: Str{err.(error).Error()}})
+			return
+		}
+		result := f.result(out)
+		state.Push(Cons{Car: Bool{true}, Cdr: result})
+		return
+	}
+	state.Push(f.result(out))
+}
+
+func (f goFunc) result(out []reflect.Value) Value {
+	values := make([]Value, len(out))
+	for i, o := range out {
+		v, err := toValue(o)
+		if err != nil {
+			panic(EvaluationError{Msg: f.name + ": result: " + err.Error()})
+		}
+		values[i] = v
+	}
+	switch len(values) {
+	case 0:
+		return Nil{}
+	case 1:
+		return values[0]
+	default:
+		return List(values...)
+	}
+}
@@ -0,0 +1,75 @@
+package golisp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterFunc(t *testing.T) {
+	context := NewContext()
+	context.RegisterFunc("add", func(a, b int) int { return a + b })
+	context.RegisterFunc("sum", func(init float64, xs ...float64) float64 {
+		for _, x := range xs {
+			init += x
+		}
+		return init
+	})
+	context.RegisterFunc("div", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	})
+	context.RegisterFunc("split", func(s string) (string, string) {
+		before, after, _ := strings.Cut(s, ",")
+		return before, after
+	})
+	context.RegisterFunc("nothing", func() {})
+	context.RegisterFunc("car", func(c Cons) Value { return c.Car })
+	context.RegisterFunc("words", func(s string) []string { return strings.Fields(s) })
+	for name := range context.Builtins {
+		if _, err := context.Eval(List(Sym{"def"}, Sym{name}, List(Sym{"builtin"}, Sym{name}))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		src, want string
+	}{
+		{`(add 1 2)`, `3`},
+		{`(sum 1 2 3.5)`, `6.5`},
+		{`(sum 1)`, `1.0`},
+		{`(div 7 2)`, `(#t . 3)`},
+		{`(div 7 0)`, `(#f . "division by zero")`},
+		{`(split "a,b")`, `("a" "b")`},
+		{`(nothing)`, `()`},
+		{`(car '(1 2))`, `1`},
+		{`(words " x y ")`, `("x" "y")`},
+		{`(add 1)`, `error: 1:2: Evaluation error: add takes 2 arguments`},
+		{`(add 1 2 3)`, `error: 1:2: Evaluation error: add takes 2 arguments`},
+		{`(sum)`, `error: 1:2: Evaluation error: sum takes at least 1 arguments`},
+		{`(add 1 "2")`, `error: 1:2: Evaluation error: add: argument 2: Expected integer but got "2"`},
+		{`(sum 1 2 'x)`, `error: 1:2: Evaluation error: sum: argument 3: Expected number but got x`},
+		{`(car 1)`, `error: 1:2: Evaluation error: car: argument 1: Expected cons but got 1`},
+	}
+	for _, test := range tests {
+		result, err := evalString(context, "", test.src)
+		var got string
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = result.Inspect()
+		}
+		if got != test.want {
+			t.Errorf("%s\n got: %s\nwant: %s", test.src, got, test.want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterFunc accepted a non-function")
+		}
+	}()
+	context.RegisterFunc("one", 1)
+}
@@ -2,53 +2,85 @@ package main
 
 import (
 	"bufio"
-	_ "embed"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/yubrot/golisp"
+	"github.com/yubrot/golisp/stdlib"
 )
 
-//go:embed rosetta-lisp/boot.lisp
-var bootcode string
+type options struct {
+	test         bool
+	files, args  []string
+	argsStarted  bool
+	capabilities golisp.Capability
+}
+
+func parseArgs(argv []string) (opts options, err error) {
+	opts.capabilities = golisp.AllCapabilities
+	if len(argv) >= 1 && argv[0] == "-test" {
+		opts.test = true
+		argv = argv[1:]
+	}
+	for _, s := range argv {
+		if opts.argsStarted {
+			opts.args = append(opts.args, s)
+		} else if s == "--" && !opts.test {
+			opts.argsStarted = true
+		} else if strings.HasPrefix(s, "--sandbox=") {
+			opts.capabilities, err = golisp.ParseCapability(strings.TrimPrefix(s, "--sandbox="))
+			if err != nil {
+				return
+			}
+		} else {
+			opts.files = append(opts.files, s)
+		}
+	}
+	return
+}
 
 func main() {
-	ctx := golisp.NewContext()
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx := newContext(opts)
 
-	if len(os.Args) == 0 || len(os.Args) == 1 {
-		initContext(ctx, true, []string{})
-		repl(ctx)
-	} else if os.Args[1] == "-test" {
-		initContext(ctx, false, []string{})
-		for _, test := range os.Args[2:] {
+	if opts.test {
+		for _, test := range opts.files {
 			RunTest(ctx, test)
 		}
+		return
+	}
+
+	if len(opts.files) == 0 && !opts.argsStarted {
+		repl(ctx)
+	}
+	for _, file := range opts.files {
+		execFile(ctx, file)
+	}
+}
+
+// The sandbox applies to the test runner as well as to programs
+func newContext(opts options) *golisp.Context {
+	ctx := golisp.NewContext()
+	ctx.Capabilities = opts.capabilities
+	if opts.test {
+		initContext(ctx, false, []string{})
 	} else {
-		var files, args []string
-		argsStarted := false
-		for _, s := range os.Args[1:] {
-			if argsStarted {
-				args = append(args, s)
-			} else if s == "--" {
-				argsStarted = true
-			} else {
-				files = append(files, s)
-			}
-		}
-		initContext(ctx, true, args)
-		for _, file := range files {
-			execFile(ctx, file)
-		}
+		initContext(ctx, true, opts.args)
 	}
+	return ctx
 }
 
 func initContext(ctx *golisp.Context, boot bool, args []string) {
-	registerBuiltins(ctx, args)
+	stdlib.Register(ctx, stdlib.Options{Args: args})
 	if boot {
-		buf := bufio.NewReader(strings.NewReader(bootcode))
-		err := exec(ctx, buf)
+		err := stdlib.Boot(ctx)
 		if err != nil {
 			panic(errors.New("initContext: " + err.Error()))
 		}
@@ -63,19 +95,27 @@ func execFile(context *golisp.Context, file string) {
 	defer fp.Close()
 
 	buf := bufio.NewReader(fp)
-	err = exec(context, buf)
+	err = exec(context, file, buf)
 	if err != nil {
-		panic(errors.New(file + ": " + err.Error()))
+		printError(err)
+		os.Exit(1)
 	}
 }
 
-func exec(context *golisp.Context, buf *bufio.Reader) error {
-	return golisp.RunParser(buf, func(expr golisp.Value, err error) error {
+func exec(context *golisp.Context, file string, buf *bufio.Reader) error {
+	reader := context.NewReader(file, buf)
+	for {
+		expr, err := reader.ReadValue()
+		if err == io.EOF {
+			return nil
+		}
 		if err == nil {
 			_, err = context.Eval(expr)
 		}
-		return err
-	})
+		if err != nil {
+			return err
+		}
+	}
 }
 
 func repl(context *golisp.Context) {
@@ -83,22 +123,29 @@ func repl(context *golisp.Context) {
 	fmt.Fprint(os.Stderr, "> ")
 
 	stdin := bufio.NewReader(os.Stdin)
-	_ = golisp.RunParser(stdin, func(expr golisp.Value, err error) (never error) {
-		defer fmt.Fprint(os.Stderr, "> ")
-
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			_, _, _ = stdin.ReadLine()
+	reader := context.NewReader("", stdin)
+	for {
+		expr, err := reader.ReadValue()
+		if err == io.EOF {
 			return
 		}
-
-		result, err := context.Eval(expr)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
-			return
+			_, _, _ = stdin.ReadLine()
+		} else if result, err := context.Eval(expr); err != nil {
+			printError(err)
+		} else {
+			fmt.Println(result.Inspect())
 		}
+		fmt.Fprint(os.Stderr, "> ")
+	}
+}
 
-		fmt.Println(result.Inspect())
-		return
-	})
+func printError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	if e, ok := err.(golisp.EvaluationError); ok {
+		for _, frame := range e.Trace {
+			fmt.Fprintln(os.Stderr, "  "+frame.String())
+		}
+	}
 }
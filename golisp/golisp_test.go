@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/yubrot/golisp"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want options
+		err  string
+	}{
+		{nil, options{capabilities: golisp.AllCapabilities}, ""},
+		{[]string{"a.lisp", "--", "x", "--sandbox=pure"}, options{files: []string{"a.lisp"}, args: []string{"x", "--sandbox=pure"}, argsStarted: true, capabilities: golisp.AllCapabilities}, ""},
+		{[]string{"--sandbox=io-read,console", "a.lisp"}, options{files: []string{"a.lisp"}, capabilities: golisp.IORead | golisp.Console}, ""},
+		{[]string{"--sandbox=pure"}, options{capabilities: golisp.Pure}, ""},
+		{[]string{"-test", "t1", "--sandbox=pure", "t2"}, options{test: true, files: []string{"t1", "t2"}, capabilities: golisp.Pure}, ""},
+		{[]string{"-test", "--sandbox=net"}, options{}, "Unknown capability: net"},
+		{[]string{"--sandbox="}, options{}, "Unknown capability: "},
+	}
+	for _, test := range tests {
+		got, err := parseArgs(test.argv)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseArgs(%q): expected error %q but got %v", test.argv, test.err, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseArgs(%q) = %+v, %v, expected %+v", test.argv, got, err, test.want)
+		}
+	}
+}
+
+func TestSandboxedTest(t *testing.T) {
+	opts, err := parseArgs([]string{"-test", "--sandbox=pure"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(opts)
+	_, err = ctx.Eval(golisp.List(golisp.List(golisp.Sym{Data: "builtin"}, golisp.Sym{Data: "exit"}), golisp.Int{Data: 1}))
+	if !errors.As(err, new(golisp.PermissionDenied)) {
+		t.Errorf("got %v", err)
+	}
+}
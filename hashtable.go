@@ -0,0 +1,126 @@
+package golisp
+
+import (
+	"strings"
+)
+
+type HashTable struct {
+	table *hashTable
+}
+
+// Entries are kept in insertion order. Each bucket holds indices of the entries with the hash code,
+// and deleted entries are compacted away once they outnumber the live ones.
+type hashTable struct {
+	buckets map[uint64][]int
+	entries []hashEntry
+	count   int
+}
+
+type hashEntry struct {
+	code    uint64
+	key     Value
+	value   Value
+	deleted bool
+}
+
+func NewHashTable() HashTable {
+	return HashTable{&hashTable{buckets: make(map[uint64][]int)}}
+}
+
+func (t *hashTable) find(code uint64, key Value) (int, bool) {
+	for _, i := range t.buckets[code] {
+		if Equal(t.entries[i].key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (h HashTable) Get(key Value) (Value, bool) {
+	i, ok := h.table.find(Hash(key), key)
+	if !ok {
+		return nil, false
+	}
+	return h.table.entries[i].value, true
+}
+
+func (h HashTable) Set(key, value Value) {
+	code := Hash(key)
+	if i, ok := h.table.find(code, key); ok {
+		h.table.entries[i].value = value
+		return
+	}
+	h.table.buckets[code] = append(h.table.buckets[code], len(h.table.entries))
+	h.table.entries = append(h.table.entries, hashEntry{code: code, key: key, value: value})
+	h.table.count++
+}
+
+func (h HashTable) Delete(key Value) {
+	code := Hash(key)
+	i, ok := h.table.find(code, key)
+	if !ok {
+		return
+	}
+	bucket := h.table.buckets[code]
+	for j, index := range bucket {
+		if index == i {
+			bucket = append(bucket[:j:j], bucket[j+1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(h.table.buckets, code)
+	} else {
+		h.table.buckets[code] = bucket
+	}
+	h.table.entries[i] = hashEntry{deleted: true}
+	h.table.count--
+	if len(h.table.entries) > 2*h.table.count+8 {
+		h.table.compact()
+	}
+}
+
+func (t *hashTable) compact() {
+	entries := make([]hashEntry, 0, t.count)
+	t.buckets = make(map[uint64][]int)
+	for _, entry := range t.entries {
+		if !entry.deleted {
+			t.buckets[entry.code] = append(t.buckets[entry.code], len(entries))
+			entries = append(entries, entry)
+		}
+	}
+	t.entries = entries
+}
+
+func (h HashTable) Len() int {
+	return h.table.count
+}
+
+func (h HashTable) Entries() []Cons {
+	entries := make([]Cons, 0, h.table.count)
+	for _, entry := range h.table.entries {
+		if !entry.deleted {
+			entries = append(entries, Cons{Car: entry.key, Cdr: entry.value})
+		}
+	}
+	return entries
+}
+
+func (h HashTable) Inspect() string {
+	return h.inspect(&trail{})
+}
+
+func (h HashTable) inspect(t *trail) (s string) {
+	p, back := t.backref(h.table)
+	if back {
+		return cycleMarker
+	}
+	t.within(p, func() {
+		var entries []string
+		for _, entry := range h.Entries() {
+			entries = append(entries, entry.inspect(t))
+		}
+		s = "#hash(" + strings.Join(entries, " ") + ")"
+	})
+	return
+}
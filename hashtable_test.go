@@ -0,0 +1,26 @@
+package golisp
+
+import "testing"
+
+func TestHashTableCompaction(t *testing.T) {
+	table := NewHashTable()
+	for i := 0; i < 100; i++ {
+		table.Set(Int{Data: int64(i)}, Nil{})
+	}
+	for i := 0; i < 95; i++ {
+		table.Delete(Int{Data: int64(i)})
+	}
+	table.Set(Int{Data: 0}, Nil{})
+	var entries []Value
+	for _, entry := range table.Entries() {
+		entries = append(entries, entry)
+	}
+	if got := List(entries...).Inspect(); got != "((95) (96) (97) (98) (99) (0))" || table.Len() != 6 {
+		t.Errorf("Entries() = %s", got)
+	}
+	for i := 95; i < 100; i++ {
+		if _, ok := table.Get(Int{Data: int64(i)}); !ok {
+			t.Errorf("%d is lost", i)
+		}
+	}
+}
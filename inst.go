@@ -10,11 +10,13 @@ type ldc struct {
 
 type ldv struct {
 	name string
+	pos  *Pos
 }
 
 type ldf struct {
 	pattern pattern
 	code    Code
+	name    string
 }
 
 type ldm struct {
@@ -24,6 +26,7 @@ type ldm struct {
 
 type ldb struct {
 	name string
+	pos  *Pos
 }
 
 type sel struct {
@@ -32,6 +35,7 @@ type sel struct {
 
 type app struct {
 	argc int
+	pos  *Pos
 }
 
 type leave struct{}
@@ -44,4 +48,58 @@ type def struct {
 
 type set struct {
 	name string
+	pos  *Pos
+}
+
+func locateCode(code Code, pos *Pos) Code {
+	if pos == nil {
+		return code
+	}
+	for j, i := range code {
+		switch i := i.(type) {
+		case ldv:
+			if i.pos == nil {
+				i.pos = pos
+				code[j] = i
+			}
+		case ldb:
+			if i.pos == nil {
+				i.pos = pos
+				code[j] = i
+			}
+		case app:
+			if i.pos == nil {
+				i.pos = pos
+				code[j] = i
+			}
+		case set:
+			if i.pos == nil {
+				i.pos = pos
+				code[j] = i
+			}
+		case ldf:
+			locateCode(i.code, pos)
+		case ldm:
+			locateCode(i.code, pos)
+		case sel:
+			locateCode(i.a, pos)
+			locateCode(i.b, pos)
+		}
+	}
+	return code
+}
+
+func instPos(i inst) *Pos {
+	switch i := i.(type) {
+	case ldv:
+		return i.pos
+	case ldb:
+		return i.pos
+	case app:
+		return i.pos
+	case set:
+		return i.pos
+	default:
+		return nil
+	}
 }
@@ -1,63 +1,81 @@
 package golisp
 
-//go:generate go run -mod vendor golang.org/x/tools/cmd/goyacc -o parser.go parser.go.y
-
 import (
 	"bufio"
-	"errors"
 	"strconv"
 	"unicode"
+	"unicode/utf8"
 )
 
-func RunParser(reader *bufio.Reader, handler func(Value, error) error) (err error) {
-	yyErrorVerbose = true
-	lex := &lexer{reader: reader}
-	for {
-		lex.skipSpaces()
-		if lex.peek() == eof {
-			break
-		}
-		yyParse(lex)
-		result := lex.result
-		err = lex.err
-		lex.result = nil
-		lex.err = nil
-		err = handler(result, err)
-		if err != nil {
-			break
-		}
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (pos *Pos) String() string {
+	s := strconv.Itoa(pos.Line) + ":" + strconv.Itoa(pos.Col)
+	if pos.File != "" {
+		s = pos.File + ":" + s
 	}
-	return
+	return s
 }
 
+const (
+	SYM = iota + 1
+	STR
+	NUM
+	CHAR
+	LPAREN
+	RPAREN
+	LBRACK
+	RBRACK
+	VEC_OPEN
+	BYTES_OPEN
+	DOT
+	TRUE
+	FALSE
+	QUOTE
+	QUASIQUOTE
+	UNQUOTE
+	UNQUOTE_SPLICING
+	READER_MACRO
+	UNUSED
+)
+
 type token struct {
 	typ int
 	lit string
 	str string
-	num float64
+	val Value
+	pos *Pos
 }
 
 type lexer struct {
 	reader  *bufio.Reader
 	current []rune
 
-	result Value
-	err    error
+	pos     Pos
+	prevPos Pos
+	start   Pos
+
+	isMacro func(name string) bool
+	err     ParseError
 }
 
-func (l *lexer) Lex(lval *yySymType) int {
-	lval.tok = l.next()
-	return lval.tok.typ
+func (l *lexer) takeError() ParseError {
+	err := l.err
+	l.err = ParseError{}
+	return err
 }
 
-func (l *lexer) Error(msg string) {
-	if l.err == nil {
-		l.err = errors.New(msg)
-	}
+func (l *lexer) errorf(msg string) ParseError {
+	return ParseError{Msg: msg, Pos: l.start}
 }
 
 func (l *lexer) next() token {
 	l.skipSpaces()
+	l.start = l.pos
 	c := l.read()
 
 	switch {
@@ -80,12 +98,35 @@ func (l *lexer) next() token {
 		return l.emit(DOT)
 
 	case c == '#':
+		if name := l.peekName(); name != "" && l.isMacro != nil && l.isMacro(name) {
+			return l.nextReaderMacro(name)
+		}
 		c = l.read()
 		switch c {
 		case 't':
 			return l.emit(TRUE)
 		case 'f':
 			return l.emit(FALSE)
+		case '\\':
+			return l.nextChar()
+		case '(':
+			return l.emit(VEC_OPEN)
+		case 'r':
+			return l.nextRawStr()
+		case 'x', 'b', 'o':
+			l.readWhile(func(c rune) bool {
+				return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '+' || c == '-'
+			})
+			return l.emitNum()
+		case '|':
+			return l.nextAfterBlockComment()
+		case ';':
+			return l.nextAfterDatumComment()
+		case 'u':
+			if l.read() == '8' && l.read() == '(' {
+				return l.emit(BYTES_OPEN)
+			}
+			return l.fail("Expected #u8(")
 		default:
 			return l.fail("Unexpected character: " + string(c))
 		}
@@ -106,19 +147,21 @@ func (l *lexer) next() token {
 	case unicode.IsLetter(c) || isSpecial(c):
 		// ['+' '-'] digit
 		if (c == '+' || c == '-') && unicode.IsDigit(l.peek()) {
-			op := l.emit(UNUSED)
-			r := l.nextNum()
-			r.lit = op.lit + r.lit
-			if op.lit == "-" {
-				r.num = -r.num
-			}
-			return r
+			return l.nextNum()
 		}
 
 		l.readWhile(func(c rune) bool {
 			return unicode.IsLetter(c) || unicode.IsDigit(c) || isSpecial(c)
 		})
-		return l.emit(SYM)
+		// +inf.0, -inf.0, +nan.0
+		if lit := string(l.current); (lit == "+inf" || lit == "-inf" || lit == "+nan" || lit == "-nan") && l.peek() == '.' {
+			l.read()
+			l.readWhile(unicode.IsDigit)
+			return l.emitNum()
+		}
+		r := l.emit(SYM)
+		r.str = r.lit
+		return r
 
 	case unicode.IsDigit(c):
 		l.unread(c)
@@ -128,6 +171,9 @@ func (l *lexer) next() token {
 		l.unread(c)
 		return l.nextStr()
 
+	case c == '|':
+		return l.nextQuotedSym()
+
 	default:
 		return l.fail("Unexpected character: " + string(c))
 	}
@@ -156,13 +202,131 @@ func (l *lexer) skipSpaces() {
 	}
 }
 
+// Reader macro names consist of ASCII letters, digits and '-'
+func (l *lexer) peekName() string {
+	for n := 1; n <= 64; n++ {
+		b, _ := l.reader.Peek(n)
+		if len(b) < n {
+			return string(b)
+		}
+		c := b[n-1]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+			return string(b[:n-1])
+		}
+	}
+	return ""
+}
+
+// #name{...} passes the text inside the braces to the reader macro as a string
+func (l *lexer) nextReaderMacro(name string) token {
+	for range name {
+		l.read()
+	}
+	if l.peek() != '{' {
+		r := l.emit(READER_MACRO)
+		r.str = name
+		return r
+	}
+
+	l.read()
+	body := []rune{}
+	depth := 1
+	for {
+		c := l.read()
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			body = append(body, c)
+			for c = l.read(); c != '"' && c != eof; c = l.read() {
+				body = append(body, c)
+				if c == '\\' {
+					c = l.read()
+					body = append(body, c)
+				}
+			}
+		case eof:
+			return l.fail("Reader macro is not terminated: #" + name + "{")
+		}
+		if depth == 0 {
+			r := l.emit(READER_MACRO)
+			r.str = name
+			r.val = Str{Data: string(body)}
+			return r
+		}
+		body = append(body, c)
+	}
+}
+
+func (l *lexer) nextAfterBlockComment() token {
+	depth := 1
+	for depth > 0 {
+		switch l.read() {
+		case '|':
+			if l.peek() == '#' {
+				l.read()
+				depth--
+			}
+		case '#':
+			if l.peek() == '|' {
+				l.read()
+				depth++
+			}
+		case eof:
+			return l.fail("Block comment is not terminated")
+		}
+	}
+	l.discard()
+	return l.next()
+}
+
+func (l *lexer) nextAfterDatumComment() token {
+	l.discard()
+	depth := 0
+	for {
+		tok := l.next()
+		switch tok.typ {
+		case UNUSED:
+			return tok
+		case 0:
+			return l.fail("Datum comment is not followed by a datum")
+		case LPAREN, LBRACK, VEC_OPEN, BYTES_OPEN:
+			depth++
+		case RPAREN, RBRACK:
+			if depth == 0 {
+				return l.fail("Datum comment is not followed by a datum")
+			}
+			depth--
+		case QUOTE, QUASIQUOTE, UNQUOTE, UNQUOTE_SPLICING:
+			continue
+		case READER_MACRO:
+			// Without a {...} body, a reader macro takes the following datum
+			if tok.val == nil {
+				continue
+			}
+		}
+		if depth == 0 {
+			return l.next()
+		}
+	}
+}
+
 func (l *lexer) nextNum() token {
-	l.readWhile(unicode.IsDigit)
+	l.readWhile(isDigitOrSeparator)
+
+	// ratio
+	if l.peek() == '/' {
+		l.read()
+		l.readWhile(isDigitOrSeparator)
+		return l.emitNum()
+	}
 
 	// frac
 	if l.peek() == '.' {
 		l.read()
-		l.readWhile(unicode.IsDigit)
+		l.readWhile(isDigitOrSeparator)
 	}
 
 	// exp
@@ -171,21 +335,96 @@ func (l *lexer) nextNum() token {
 		if l.peek() == '-' || l.peek() == '+' {
 			l.read()
 		}
-		l.readWhile(unicode.IsDigit)
+		l.readWhile(isDigitOrSeparator)
 	}
 
+	return l.emitNum()
+}
+
+func isDigitOrSeparator(c rune) bool {
+	return unicode.IsDigit(c) || c == '_'
+}
+
+func (l *lexer) emitNum() token {
 	r := l.emit(NUM)
-	num, err := strconv.ParseFloat(r.lit, 64)
+	num, err := ParseNum(r.lit)
 	if err != nil {
-		panic(err)
+		return l.fail("Invalid number: " + r.lit)
 	}
-	r.num = num
+	r.val = num
 	return r
 }
 
+// The first name of each character is used for printing
+var charNames = []struct {
+	name string
+	code rune
+}{
+	{"nul", 0},
+	{"alarm", 7},
+	{"backspace", 8},
+	{"tab", '\t'},
+	{"newline", '\n'},
+	{"return", '\r'},
+	{"escape", 27},
+	{"space", ' '},
+	{"delete", 127},
+	{"null", 0},
+	{"linefeed", '\n'},
+}
+
+func (l *lexer) nextChar() token {
+	c := l.read()
+	if c == eof {
+		return l.fail("Character is not terminated")
+	}
+	name := []rune{c}
+	if unicode.IsLetter(c) {
+		for unicode.IsLetter(l.peek()) || unicode.IsDigit(l.peek()) {
+			name = append(name, l.read())
+		}
+	}
+
+	r := l.emit(CHAR)
+	if len(name) == 1 {
+		r.val = Char{c}
+		return r
+	}
+	for _, c := range charNames {
+		if c.name == string(name) {
+			r.val = Char{c.code}
+			return r
+		}
+	}
+	if name[0] == 'x' {
+		code, err := strconv.ParseUint(string(name[1:]), 16, 32)
+		if err == nil && utf8.ValidRune(rune(code)) {
+			r.val = Char{rune(code)}
+			return r
+		}
+	}
+	return l.fail("Unknown character name: " + string(name))
+}
+
+// Each escape sequence is also used for printing
+var strEscapes = []struct {
+	name rune
+	code rune
+}{
+	{'\\', '\\'},
+	{'"', '"'},
+	{'n', '\n'},
+	{'t', '\t'},
+	{'r', '\r'},
+	{'0', 0},
+	{'a', 7},
+	{'b', 8},
+	{'e', 27},
+}
+
 func (l *lexer) nextStr() token {
 	l.read() // read '"'
-	buf := []rune{}
+	buf := []byte{}
 
 	for {
 		c := l.read()
@@ -196,18 +435,203 @@ func (l *lexer) nextStr() token {
 			return r
 
 		case '\\':
+			var ok bool
+			buf, ok = l.readEscape(buf)
+			if !ok {
+				return l.skipUntil('"')
+			}
+
+		case eof:
+			return l.fail("String is not terminated")
+
+		default:
+			buf = utf8.AppendRune(buf, c)
+		}
+	}
+}
+
+func (l *lexer) nextQuotedSym() token {
+	buf := []byte{}
+
+	for {
+		c := l.read()
+		switch c {
+		case '|':
+			r := l.emit(SYM)
+			r.str = string(buf)
+			return r
+
+		case '\\':
+			if l.peek() == '|' {
+				buf = append(buf, byte(l.read()))
+				continue
+			}
+			var ok bool
+			buf, ok = l.readEscape(buf)
+			if !ok {
+				return l.skipUntil('|')
+			}
+
+		case eof:
+			return l.fail("Symbol is not terminated")
+
+		default:
+			buf = utf8.AppendRune(buf, c)
+		}
+	}
+}
+
+// skipUntil skips the rest of a malformed string or symbol so that the reader can continue after it
+func (l *lexer) skipUntil(term rune) token {
+	for {
+		switch l.read() {
+		case term, eof:
+			l.discard()
+			return token{typ: UNUSED}
+		case '\\':
+			l.read()
+		}
+	}
+}
+
+func isPlainSym(s string) bool {
+	for i, c := range s {
+		switch {
+		case i == 0 && (c == '+' || c == '-') && len(s) > 1 && unicode.IsDigit([]rune(s)[1]):
+			return false
+		case i == 0 && !unicode.IsLetter(c) && !isSpecial(c):
+			return false
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c) && !isSpecial(c):
+			return false
+		}
+	}
+	return s != ""
+}
+
+// readEscape appends the character of an escape sequence to buf. A line continuation appends nothing.
+func (l *lexer) readEscape(buf []byte) ([]byte, bool) {
+	c := l.read()
+	for _, e := range strEscapes {
+		if c == e.name {
+			return utf8.AppendRune(buf, e.code), true
+		}
+	}
+
+	switch {
+	case c == 'x':
+		digits := l.readHex()
+		if l.peek() != ';' {
+			l.fail("Expected ; after \\x" + digits)
+			return buf, false
+		}
+		l.read()
+		return l.appendCodePoint(buf, digits)
+
+	case c == 'u' && l.peek() == '8':
+		// \u8{XX} is a single byte, which can make a string that is not valid UTF-8
+		l.read()
+		if l.peek() != '{' {
+			l.fail("Expected { after \\u8")
+			return buf, false
+		}
+		l.read()
+		digits := l.readHex()
+		if l.peek() != '}' || len(digits) == 0 || len(digits) > 2 {
+			l.fail("Expected a byte in \\u8{" + digits)
+			return buf, false
+		}
+		l.read()
+		code, _ := strconv.ParseUint(digits, 16, 8)
+		return append(buf, byte(code)), true
+
+	case c == 'u':
+		if l.peek() != '{' {
+			l.fail("Expected { after \\u")
+			return buf, false
+		}
+		l.read()
+		digits := l.readHex()
+		if l.peek() != '}' {
+			l.fail("Expected } after \\u{" + digits)
+			return buf, false
+		}
+		l.read()
+		return l.appendCodePoint(buf, digits)
+
+	case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		if c == ' ' || c == '\t' {
+			l.readWhile(func(c rune) bool { return c == ' ' || c == '\t' })
+			if l.peek() != '\r' && l.peek() != '\n' {
+				l.fail("Expected a newline after \\ for line continuation")
+				return buf, false
+			}
 			c = l.read()
-			switch c {
-			case '\\':
-				buf = append(buf, '\\')
-			case 't':
-				buf = append(buf, '\t')
-			case 'n':
-				buf = append(buf, '\n')
-			case '"':
-				buf = append(buf, '"')
-			default:
-				return l.fail("Unsupported escape sequence: " + string(c))
+		}
+		if c == '\r' && l.peek() == '\n' {
+			l.read()
+		}
+		l.readWhile(func(c rune) bool { return c == ' ' || c == '\t' })
+		return buf, true
+
+	case c == eof:
+		l.fail("String is not terminated")
+		return buf, false
+
+	default:
+		l.fail("Unsupported escape sequence: \\" + string(c))
+		return buf, false
+	}
+}
+
+func (l *lexer) readHex() string {
+	var digits []rune
+	for {
+		c := l.read()
+		if !unicode.Is(unicode.ASCII_Hex_Digit, c) {
+			l.unread(c)
+			return string(digits)
+		}
+		digits = append(digits, c)
+	}
+}
+
+func (l *lexer) appendCodePoint(buf []byte, digits string) ([]byte, bool) {
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		l.fail("Invalid code point: " + digits)
+		return buf, false
+	}
+	return utf8.AppendRune(buf, rune(code)), true
+}
+
+func (l *lexer) nextRawStr() token {
+	hashes := 0
+	for l.peek() == '#' {
+		l.read()
+		hashes++
+	}
+	if l.read() != '"' {
+		return l.fail("Expected \" after #r")
+	}
+	buf := []rune{}
+
+	for {
+		c := l.read()
+		switch c {
+		case '"':
+			n := 0
+			for n < hashes && l.peek() == '#' {
+				l.read()
+				n++
+			}
+			if n == hashes {
+				r := l.emit(STR)
+				r.str = string(buf)
+				return r
+			}
+			buf = append(buf, c)
+			for ; n > 0; n-- {
+				buf = append(buf, '#')
 			}
 
 		case eof:
@@ -222,15 +646,22 @@ func (l *lexer) nextStr() token {
 var eof = rune(0)
 
 func (l *lexer) read() rune {
-	if l.result != nil {
-		return eof
-	}
-
 	c, _, err := l.reader.ReadRune()
 	if err != nil {
 		return eof
 	}
 
+	if len(l.current) == 0 {
+		l.start = l.pos
+	}
+	l.prevPos = l.pos
+	if c == '\n' {
+		l.pos.Line++
+		l.pos.Col = 1
+	} else {
+		l.pos.Col++
+	}
+
 	l.current = append(l.current, c)
 	return c
 }
@@ -240,6 +671,7 @@ func (l *lexer) unread(c rune) {
 		return
 	}
 	_ = l.reader.UnreadRune()
+	l.pos = l.prevPos
 	l.current = l.current[:len(l.current)-1]
 }
 
@@ -264,14 +696,15 @@ func (l *lexer) discard() {
 }
 
 func (l *lexer) emit(typ int) token {
-	r := token{typ: typ, lit: string(l.current)}
+	start := l.start
+	r := token{typ: typ, lit: string(l.current), pos: &start}
 	l.current = l.current[:0]
 	return r
 }
 
 func (l *lexer) fail(msg string) token {
 	l.current = l.current[:0]
-	l.err = errors.New(msg)
+	l.err = l.errorf(msg)
 	return token{typ: UNUSED}
 }
 
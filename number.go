@@ -0,0 +1,208 @@
+package golisp
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type Int struct {
+	Data int64
+	Big  *big.Int
+}
+
+type Rat struct {
+	Data *big.Rat
+}
+
+func MakeInt(b *big.Int) Int {
+	if b.IsInt64() {
+		return Int{Data: b.Int64()}
+	}
+	return Int{Big: b}
+}
+
+func MakeRat(r *big.Rat) Value {
+	if r.IsInt() {
+		return MakeInt(new(big.Int).Set(r.Num()))
+	}
+	return Rat{r}
+}
+
+func (i Int) ToBig() *big.Int {
+	if i.Big != nil {
+		return new(big.Int).Set(i.Big)
+	}
+	return big.NewInt(i.Data)
+}
+
+func (i Int) Float64() float64 {
+	if i.Big != nil {
+		f, _ := new(big.Float).SetInt(i.Big).Float64()
+		return f
+	}
+	return float64(i.Data)
+}
+
+func (i Int) Inspect() string {
+	if i.Big != nil {
+		return i.Big.String()
+	}
+	return strconv.FormatInt(i.Data, 10)
+}
+
+func (r Rat) Inspect() string {
+	return r.Data.RatString()
+}
+
+func IsNumber(v Value) bool {
+	switch v.(type) {
+	case Int, Rat, Num:
+		return true
+	default:
+		return false
+	}
+}
+
+func ToFloat(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case Int:
+		return v.Float64(), true
+	case Rat:
+		f, _ := v.Data.Float64()
+		return f, true
+	case Num:
+		return v.Data, true
+	default:
+		return 0, false
+	}
+}
+
+func ToRat(v Value) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case Int:
+		return new(big.Rat).SetInt(v.ToBig()), true
+	case Rat:
+		return new(big.Rat).Set(v.Data), true
+	default:
+		return nil, false
+	}
+}
+
+var radixPrefixes = map[string]int{"#x": 16, "#b": 2, "#o": 8}
+
+var specialNums = map[string]float64{
+	"+inf.0": math.Inf(1),
+	"-inf.0": math.Inf(-1),
+	"+nan.0": math.NaN(),
+	"-nan.0": math.NaN(),
+}
+
+func ParseNum(lit string) (Value, error) {
+	invalid := errors.New("Invalid number: " + lit)
+	if num, ok := specialNums[lit]; ok {
+		return Num{num}, nil
+	}
+
+	base := 10
+	body := lit
+	if len(lit) >= 2 {
+		if b, ok := radixPrefixes[lit[:2]]; ok {
+			base = b
+			body = lit[2:]
+		}
+	}
+	body, ok := stripSeparators(body, base)
+	if !ok {
+		return nil, invalid
+	}
+	if base != 10 {
+		i, ok := new(big.Int).SetString(body, base)
+		if !ok {
+			return nil, invalid
+		}
+		return MakeInt(i), nil
+	}
+
+	// The parsers of Go also accept forms such as 0x1p4 or 0o17/2, which the reader does not
+	if !isDecimal(body) {
+		return nil, invalid
+	}
+	if num, den, ok := strings.Cut(body, "/"); ok {
+		n, _ := new(big.Int).SetString(num, 10)
+		d, _ := new(big.Int).SetString(den, 10)
+		if d.Sign() == 0 {
+			return nil, invalid
+		}
+		return MakeRat(new(big.Rat).SetFrac(n, d)), nil
+	}
+	if !strings.ContainsAny(body, ".eE") {
+		if n, err := strconv.ParseInt(body, 10, 64); err == nil {
+			return Int{Data: n}, nil
+		}
+		if b, ok := new(big.Int).SetString(body, 10); ok {
+			return MakeInt(b), nil
+		}
+	}
+	num, err := strconv.ParseFloat(body, 64)
+	if err != nil || math.IsInf(num, 0) && !strings.ContainsAny(body, "eE") || math.IsNaN(num) {
+		return nil, invalid
+	}
+	return Num{num}, nil
+}
+
+// The syntax of decimal numbers: [+-]digits, followed by either /digits or [.digits][e[+-]digits]
+func isDecimal(lit string) bool {
+	i := 0
+	sign := func() {
+		if i < len(lit) && (lit[i] == '+' || lit[i] == '-') {
+			i++
+		}
+	}
+	digits := func() bool {
+		start := i
+		for i < len(lit) && '0' <= lit[i] && lit[i] <= '9' {
+			i++
+		}
+		return start < i
+	}
+
+	sign()
+	if !digits() {
+		return false
+	}
+	if i < len(lit) && lit[i] == '/' {
+		i++
+		return digits() && i == len(lit)
+	}
+	if i < len(lit) && lit[i] == '.' {
+		i++
+		digits()
+	}
+	if i < len(lit) && (lit[i] == 'e' || lit[i] == 'E') {
+		i++
+		sign()
+		if !digits() {
+			return false
+		}
+	}
+	return i == len(lit)
+}
+
+// Digit separators are only allowed between two digits
+func stripSeparators(lit string, base int) (string, bool) {
+	if !strings.Contains(lit, "_") {
+		return lit, true
+	}
+	isDigit := func(c byte) bool {
+		return strings.IndexByte("0123456789abcdef"[:base], c|0x20) >= 0
+	}
+	for i := 0; i < len(lit); i++ {
+		if lit[i] == '_' && (i == 0 || i == len(lit)-1 || !isDigit(lit[i-1]) || !isDigit(lit[i+1])) {
+			return "", false
+		}
+	}
+	return strings.ReplaceAll(lit, "_", ""), true
+}
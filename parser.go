@@ -1,603 +0,0 @@
-// Code generated by goyacc -o parser.go parser.go.y. DO NOT EDIT.
-
-//line parser.go.y:2
-package golisp
-
-import __yyfmt__ "fmt"
-
-//line parser.go.y:2
-
-//line parser.go.y:5
-type yySymType struct {
-	yys int
-	s   Value
-	ss  []Value
-	tok token
-}
-
-const SYM = 57346
-const STR = 57347
-const NUM = 57348
-const LPAREN = 57349
-const RPAREN = 57350
-const LBRACK = 57351
-const RBRACK = 57352
-const DOT = 57353
-const TRUE = 57354
-const FALSE = 57355
-const QUOTE = 57356
-const QUASIQUOTE = 57357
-const UNQUOTE = 57358
-const UNQUOTE_SPLICING = 57359
-const UNUSED = 57360
-
-var yyToknames = [...]string{
-	"$end",
-	"error",
-	"$unk",
-	"SYM",
-	"STR",
-	"NUM",
-	"LPAREN",
-	"RPAREN",
-	"LBRACK",
-	"RBRACK",
-	"DOT",
-	"TRUE",
-	"FALSE",
-	"QUOTE",
-	"QUASIQUOTE",
-	"UNQUOTE",
-	"UNQUOTE_SPLICING",
-	"UNUSED",
-}
-
-var yyStatenames = [...]string{}
-
-const yyEofCode = 1
-const yyErrCode = 2
-const yyInitialStackSize = 16
-
-//line yacctab:1
-var yyExca = [...]int8{
-	-1, 1,
-	1, -1,
-	-2, 0,
-	-1, 28,
-	8, 19,
-	10, 19,
-	11, 19,
-	-2, 21,
-}
-
-const yyPrivate = 57344
-
-const yyLast = 35
-
-var yyAct = [...]int8{
-	2, 27, 29, 19, 25, 15, 16, 18, 17, 26,
-	5, 20, 21, 22, 23, 24, 1, 0, 0, 28,
-	0, 7, 8, 6, 3, 0, 4, 0, 30, 9,
-	10, 11, 12, 13, 14,
-}
-
-var yyPact = [...]int16{
-	17, -1000, -1000, -3, -7, -1000, -1000, -1000, -1000, -1000,
-	-1000, 17, 17, 17, 17, -1000, -4, -10, 17, -1000,
-	-8, -1000, -1000, -1000, -1000, -1000, -1000, 17, -1000, -1000,
-	-1000,
-}
-
-var yyPgo = [...]int8{
-	0, 16, 0, 10, 6, 9, 8, 7,
-}
-
-var yyR1 = [...]int8{
-	0, 1, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 3, 3, 3, 3, 4, 5, 5, 6,
-	7, 7,
-}
-
-var yyR2 = [...]int8{
-	0, 1, 2, 2, 3, 3, 1, 1, 1, 1,
-	1, 1, 2, 2, 2, 2, 2, 0, 2, 2,
-	0, 2,
-}
-
-var yyChk = [...]int16{
-	-1000, -1, -2, 7, 9, -3, 6, 4, 5, 12,
-	13, 14, 15, 16, 17, 8, -4, -6, -7, 10,
-	-4, -2, -2, -2, -2, 8, -5, 11, -2, 10,
-	-2,
-}
-
-var yyDef = [...]int8{
-	0, -2, 1, 20, 20, 6, 7, 8, 9, 10,
-	11, 0, 0, 0, 0, 2, 0, 17, 0, 3,
-	0, 12, 13, 14, 15, 4, 16, 0, -2, 5,
-	18,
-}
-
-var yyTok1 = [...]int8{
-	1,
-}
-
-var yyTok2 = [...]int8{
-	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
-	12, 13, 14, 15, 16, 17, 18,
-}
-
-var yyTok3 = [...]int8{
-	0,
-}
-
-var yyErrorMessages = [...]struct {
-	state int
-	token int
-	msg   string
-}{}
-
-//line yaccpar:1
-
-/*	parser for yacc output	*/
-
-var (
-	yyDebug        = 0
-	yyErrorVerbose = false
-)
-
-type yyLexer interface {
-	Lex(lval *yySymType) int
-	Error(s string)
-}
-
-type yyParser interface {
-	Parse(yyLexer) int
-	Lookahead() int
-}
-
-type yyParserImpl struct {
-	lval  yySymType
-	stack [yyInitialStackSize]yySymType
-	char  int
-}
-
-func (p *yyParserImpl) Lookahead() int {
-	return p.char
-}
-
-func yyNewParser() yyParser {
-	return &yyParserImpl{}
-}
-
-const yyFlag = -1000
-
-func yyTokname(c int) string {
-	if c >= 1 && c-1 < len(yyToknames) {
-		if yyToknames[c-1] != "" {
-			return yyToknames[c-1]
-		}
-	}
-	return __yyfmt__.Sprintf("tok-%v", c)
-}
-
-func yyStatname(s int) string {
-	if s >= 0 && s < len(yyStatenames) {
-		if yyStatenames[s] != "" {
-			return yyStatenames[s]
-		}
-	}
-	return __yyfmt__.Sprintf("state-%v", s)
-}
-
-func yyErrorMessage(state, lookAhead int) string {
-	const TOKSTART = 4
-
-	if !yyErrorVerbose {
-		return "syntax error"
-	}
-
-	for _, e := range yyErrorMessages {
-		if e.state == state && e.token == lookAhead {
-			return "syntax error: " + e.msg
-		}
-	}
-
-	res := "syntax error: unexpected " + yyTokname(lookAhead)
-
-	// To match Bison, suggest at most four expected tokens.
-	expected := make([]int, 0, 4)
-
-	// Look for shiftable tokens.
-	base := int(yyPact[state])
-	for tok := TOKSTART; tok-1 < len(yyToknames); tok++ {
-		if n := base + tok; n >= 0 && n < yyLast && int(yyChk[int(yyAct[n])]) == tok {
-			if len(expected) == cap(expected) {
-				return res
-			}
-			expected = append(expected, tok)
-		}
-	}
-
-	if yyDef[state] == -2 {
-		i := 0
-		for yyExca[i] != -1 || int(yyExca[i+1]) != state {
-			i += 2
-		}
-
-		// Look for tokens that we accept or reduce.
-		for i += 2; yyExca[i] >= 0; i += 2 {
-			tok := int(yyExca[i])
-			if tok < TOKSTART || yyExca[i+1] == 0 {
-				continue
-			}
-			if len(expected) == cap(expected) {
-				return res
-			}
-			expected = append(expected, tok)
-		}
-
-		// If the default action is to accept or reduce, give up.
-		if yyExca[i+1] != 0 {
-			return res
-		}
-	}
-
-	for i, tok := range expected {
-		if i == 0 {
-			res += ", expecting "
-		} else {
-			res += " or "
-		}
-		res += yyTokname(tok)
-	}
-	return res
-}
-
-func yylex1(lex yyLexer, lval *yySymType) (char, token int) {
-	token = 0
-	char = lex.Lex(lval)
-	if char <= 0 {
-		token = int(yyTok1[0])
-		goto out
-	}
-	if char < len(yyTok1) {
-		token = int(yyTok1[char])
-		goto out
-	}
-	if char >= yyPrivate {
-		if char < yyPrivate+len(yyTok2) {
-			token = int(yyTok2[char-yyPrivate])
-			goto out
-		}
-	}
-	for i := 0; i < len(yyTok3); i += 2 {
-		token = int(yyTok3[i+0])
-		if token == char {
-			token = int(yyTok3[i+1])
-			goto out
-		}
-	}
-
-out:
-	if token == 0 {
-		token = int(yyTok2[1]) /* unknown char */
-	}
-	if yyDebug >= 3 {
-		__yyfmt__.Printf("lex %s(%d)\n", yyTokname(token), uint(char))
-	}
-	return char, token
-}
-
-func yyParse(yylex yyLexer) int {
-	return yyNewParser().Parse(yylex)
-}
-
-func (yyrcvr *yyParserImpl) Parse(yylex yyLexer) int {
-	var yyn int
-	var yyVAL yySymType
-	var yyDollar []yySymType
-	_ = yyDollar // silence set and not used
-	yyS := yyrcvr.stack[:]
-
-	Nerrs := 0   /* number of errors */
-	Errflag := 0 /* error recovery flag */
-	yystate := 0
-	yyrcvr.char = -1
-	yytoken := -1 // yyrcvr.char translated into internal numbering
-	defer func() {
-		// Make sure we report no lookahead when not parsing.
-		yystate = -1
-		yyrcvr.char = -1
-		yytoken = -1
-	}()
-	yyp := -1
-	goto yystack
-
-ret0:
-	return 0
-
-ret1:
-	return 1
-
-yystack:
-	/* put a state and value onto the stack */
-	if yyDebug >= 4 {
-		__yyfmt__.Printf("char %v in %v\n", yyTokname(yytoken), yyStatname(yystate))
-	}
-
-	yyp++
-	if yyp >= len(yyS) {
-		nyys := make([]yySymType, len(yyS)*2)
-		copy(nyys, yyS)
-		yyS = nyys
-	}
-	yyS[yyp] = yyVAL
-	yyS[yyp].yys = yystate
-
-yynewstate:
-	yyn = int(yyPact[yystate])
-	if yyn <= yyFlag {
-		goto yydefault /* simple state */
-	}
-	if yyrcvr.char < 0 {
-		yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
-	}
-	yyn += yytoken
-	if yyn < 0 || yyn >= yyLast {
-		goto yydefault
-	}
-	yyn = int(yyAct[yyn])
-	if int(yyChk[yyn]) == yytoken { /* valid shift */
-		yyrcvr.char = -1
-		yytoken = -1
-		yyVAL = yyrcvr.lval
-		yystate = yyn
-		if Errflag > 0 {
-			Errflag--
-		}
-		goto yystack
-	}
-
-yydefault:
-	/* default state action */
-	yyn = int(yyDef[yystate])
-	if yyn == -2 {
-		if yyrcvr.char < 0 {
-			yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
-		}
-
-		/* look through exception table */
-		xi := 0
-		for {
-			if yyExca[xi+0] == -1 && int(yyExca[xi+1]) == yystate {
-				break
-			}
-			xi += 2
-		}
-		for xi += 2; ; xi += 2 {
-			yyn = int(yyExca[xi+0])
-			if yyn < 0 || yyn == yytoken {
-				break
-			}
-		}
-		yyn = int(yyExca[xi+1])
-		if yyn < 0 {
-			goto ret0
-		}
-	}
-	if yyn == 0 {
-		/* error ... attempt to resume parsing */
-		switch Errflag {
-		case 0: /* brand new error */
-			yylex.Error(yyErrorMessage(yystate, yytoken))
-			Nerrs++
-			if yyDebug >= 1 {
-				__yyfmt__.Printf("%s", yyStatname(yystate))
-				__yyfmt__.Printf(" saw %s\n", yyTokname(yytoken))
-			}
-			fallthrough
-
-		case 1, 2: /* incompletely recovered error ... try again */
-			Errflag = 3
-
-			/* find a state where "error" is a legal shift action */
-			for yyp >= 0 {
-				yyn = int(yyPact[yyS[yyp].yys]) + yyErrCode
-				if yyn >= 0 && yyn < yyLast {
-					yystate = int(yyAct[yyn]) /* simulate a shift of "error" */
-					if int(yyChk[yystate]) == yyErrCode {
-						goto yystack
-					}
-				}
-
-				/* the current p has no shift on "error", pop stack */
-				if yyDebug >= 2 {
-					__yyfmt__.Printf("error recovery pops state %d\n", yyS[yyp].yys)
-				}
-				yyp--
-			}
-			/* there is no state on the stack with an error shift ... abort */
-			goto ret1
-
-		case 3: /* no shift yet; clobber input char */
-			if yyDebug >= 2 {
-				__yyfmt__.Printf("error recovery discards %s\n", yyTokname(yytoken))
-			}
-			if yytoken == yyEofCode {
-				goto ret1
-			}
-			yyrcvr.char = -1
-			yytoken = -1
-			goto yynewstate /* try again in the same state */
-		}
-	}
-
-	/* reduction by production yyn */
-	if yyDebug >= 2 {
-		__yyfmt__.Printf("reduce %v in:\n\t%v\n", yyn, yyStatname(yystate))
-	}
-
-	yynt := yyn
-	yypt := yyp
-	_ = yypt // guard against "declared and not used"
-
-	yyp -= int(yyR2[yyn])
-	// yyp is now the index of $0. Perform the default action. Iff the
-	// reduced production is ε, $1 is possibly out of range.
-	if yyp+1 >= len(yyS) {
-		nyys := make([]yySymType, len(yyS)*2)
-		copy(nyys, yyS)
-		yyS = nyys
-	}
-	yyVAL = yyS[yyp+1]
-
-	/* consult goto table to find next state */
-	yyn = int(yyR1[yyn])
-	yyg := int(yyPgo[yyn])
-	yyj := yyg + yyS[yyp].yys + 1
-
-	if yyj >= yyLast {
-		yystate = int(yyAct[yyg])
-	} else {
-		yystate = int(yyAct[yyj])
-		if int(yyChk[yystate]) != -yyn {
-			yystate = int(yyAct[yyg])
-		}
-	}
-	// dummy call; replaced with literal code
-	switch yynt {
-
-	case 1:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:22
-		{
-			yyVAL.s = yyDollar[1].s
-			yylex.(*lexer).result = yyVAL.s
-		}
-	case 2:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:27
-		{
-			yyVAL.s = Nil{}
-		}
-	case 3:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:28
-		{
-			yyVAL.s = Nil{}
-		}
-	case 4:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:29
-		{
-			yyVAL.s = yyDollar[2].s
-		}
-	case 5:
-		yyDollar = yyS[yypt-3 : yypt+1]
-//line parser.go.y:30
-		{
-			yyVAL.s = yyDollar[2].s
-		}
-	case 6:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:31
-		{
-			yyVAL.s = yyDollar[1].s
-		}
-	case 7:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:32
-		{
-			yyVAL.s = Num{yyDollar[1].tok.num}
-		}
-	case 8:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:33
-		{
-			yyVAL.s = Sym{yyDollar[1].tok.lit}
-		}
-	case 9:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:34
-		{
-			yyVAL.s = Str{yyDollar[1].tok.str}
-		}
-	case 10:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:35
-		{
-			yyVAL.s = Bool{true}
-		}
-	case 11:
-		yyDollar = yyS[yypt-1 : yypt+1]
-//line parser.go.y:36
-		{
-			yyVAL.s = Bool{false}
-		}
-	case 12:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:38
-		{
-			yyVAL.s = Quote(yyDollar[2].s)
-		}
-	case 13:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:39
-		{
-			yyVAL.s = Quasiquote(yyDollar[2].s)
-		}
-	case 14:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:40
-		{
-			yyVAL.s = Unquote(yyDollar[2].s)
-		}
-	case 15:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:41
-		{
-			yyVAL.s = UnquoteSplicing(yyDollar[2].s)
-		}
-	case 16:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:44
-		{
-			yyVAL.s = yyDollar[2].s
-			for i := range yyDollar[1].ss {
-				yyVAL.s = Cons{yyDollar[1].ss[len(yyDollar[1].ss)-1-i], yyVAL.s}
-			}
-		}
-	case 17:
-		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:51
-		{
-			yyVAL.s = Nil{}
-		}
-	case 18:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:52
-		{
-			yyVAL.s = yyDollar[2].s
-		}
-	case 19:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:54
-		{
-			yyVAL.ss = append(yyDollar[1].ss, yyDollar[2].s)
-		}
-	case 20:
-		yyDollar = yyS[yypt-0 : yypt+1]
-//line parser.go.y:56
-		{
-			yyVAL.ss = make([]Value, 0, 4)
-		}
-	case 21:
-		yyDollar = yyS[yypt-2 : yypt+1]
-//line parser.go.y:57
-		{
-			yyVAL.ss = append(yyDollar[1].ss, yyDollar[2].s)
-		}
-	}
-	goto yystack /* stack new state and value */
-}
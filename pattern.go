@@ -23,11 +23,11 @@ func buildPattern(value Value) (pattern pattern) {
 				value = v.Cdr
 				continue
 			} else {
-				panic(EvaluationError{"Unsupported pattern: " + v.Car.Inspect()})
+				panic(EvaluationError{Msg: "Unsupported pattern: " + v.Car.Inspect()})
 			}
 
 		default:
-			panic(EvaluationError{"Unsupported pattern: " + v.Inspect()})
+			panic(EvaluationError{Msg: "Unsupported pattern: " + v.Inspect()})
 		}
 	}
 }
@@ -38,7 +38,7 @@ func (pattern pattern) bind(args []Value, env *Env) {
 		if pattern.rest == "" {
 			prefix = "at least "
 		}
-		panic(EvaluationError{"This function takes " + prefix + strconv.Itoa(len(pattern.fixed)) + " arguments"})
+		panic(EvaluationError{Msg: "This function takes " + prefix + strconv.Itoa(len(pattern.fixed)) + " arguments"})
 	}
 	for _, param := range pattern.fixed {
 		env.Def(param, args[0])
@@ -0,0 +1,285 @@
+package golisp
+
+import (
+	"bufio"
+	"io"
+)
+
+func RunParser(reader *bufio.Reader, handler func(Value, error) error) error {
+	return RunFileParser("", reader, handler)
+}
+
+func RunFileParser(file string, reader *bufio.Reader, handler func(Value, error) error) error {
+	r := NewFileReader(file, reader)
+	for {
+		result, err := r.ReadValue()
+		if err == io.EOF {
+			return nil
+		}
+		err = handler(result, err)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ParseAll reads every well-formed expression. On a syntax error it skips to the next top-level form
+// (the point where the parentheses are balanced again, or an opening parenthesis at the first column).
+func ParseAll(file string, reader *bufio.Reader) (values []Value, errs []ParseError) {
+	r := NewFileReader(file, reader)
+	r.recovering = true
+	for {
+		result, err := r.ReadValue()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			errs = append(errs, err.(ParseError))
+			errs = append(errs, r.recover()...)
+		} else {
+			values = append(values, result)
+		}
+	}
+}
+
+type ParseError struct {
+	Msg string
+	Pos Pos
+}
+
+func (e ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+type ReaderMacro func(arg Value) (Value, error)
+
+// Names that begin the builtin # syntax, such as #t, #x1F or #u8(, cannot be taken by reader macros.
+func IsReservedReaderMacro(name string) bool {
+	switch name {
+	case "t", "f", "x", "b", "o", "r", "u8":
+		return true
+	}
+	_, err := ParseNum("#" + name)
+	return err == nil
+}
+
+type Reader struct {
+	ReadTable  map[string]ReaderMacro
+	lex        *lexer
+	pending    *token
+	depth      int
+	recovering bool
+	context    *Context
+}
+
+func NewReader(reader *bufio.Reader) *Reader {
+	return NewFileReader("", reader)
+}
+
+func NewFileReader(file string, reader *bufio.Reader) *Reader {
+	r := &Reader{lex: &lexer{reader: reader, pos: Pos{File: file, Line: 1, Col: 1}}}
+	r.lex.isMacro = func(name string) bool {
+		_, ok := r.ReadTable[name]
+		return ok && !IsReservedReaderMacro(name)
+	}
+	return r
+}
+
+// The reader shares the read table of the context, so reader macros defined by
+// evaluated expressions take effect on the following expressions. The position of a
+// symbol read at the toplevel is also told to the context, since it has no cons to hold it.
+func (context *Context) NewReader(file string, reader *bufio.Reader) *Reader {
+	r := NewFileReader(file, reader)
+	r.ReadTable = context.ReadTable
+	r.context = context
+	return r
+}
+
+// ReadValue reads the next expression. It returns io.EOF when there are no more expressions.
+func (r *Reader) ReadValue() (result Value, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			pe, ok := e.(ParseError)
+			if !ok {
+				panic(e)
+			}
+			result, err = nil, pe
+		}
+	}()
+
+	r.depth = 0
+	tok := r.token()
+	if tok.typ == 0 {
+		return nil, io.EOF
+	}
+	s := r.readDatum(tok)
+	if r.context != nil {
+		r.context.lastRead = s
+	}
+	return s.value, nil
+}
+
+func (r *Reader) recover() (errs []ParseError) {
+	for r.depth > 0 && r.pending == nil {
+		tok := r.lex.next()
+		switch tok.typ {
+		case 0:
+			return
+		case UNUSED:
+			errs = append(errs, r.lex.takeError())
+		case LPAREN, LBRACK, VEC_OPEN, BYTES_OPEN:
+			if tok.pos.Col == 1 {
+				r.pending = &tok
+				return
+			}
+			r.depth++
+		case RPAREN, RBRACK:
+			r.depth--
+		}
+	}
+	return
+}
+
+func (r *Reader) token() token {
+	var tok token
+	if r.pending != nil {
+		tok = *r.pending
+		r.pending = nil
+	} else {
+		tok = r.lex.next()
+	}
+	switch tok.typ {
+	case UNUSED:
+		panic(r.lex.takeError())
+	case LPAREN, LBRACK, VEC_OPEN, BYTES_OPEN:
+		if r.recovering && r.depth > 0 && tok.pos.Col == 1 {
+			r.pending = &tok
+			r.fail(tok, "Expected a closing parenthesis before the next top-level form")
+		}
+		r.depth++
+	case RPAREN, RBRACK:
+		r.depth--
+	}
+	return tok
+}
+
+func (r *Reader) fail(tok token, msg string) {
+	panic(ParseError{Msg: msg, Pos: *tok.pos})
+}
+
+func (r *Reader) unexpected(tok token) {
+	if tok.typ == 0 {
+		r.fail(tok, "Unexpected end of input")
+	}
+	r.fail(tok, "Unexpected "+tok.lit)
+}
+
+type located struct {
+	value Value
+	pos   *Pos
+}
+
+func quoted(tok token, name string, s located) located {
+	return located{Cons{Sym{name}, Cons{s.value, Nil{}, s.pos}, tok.pos}, tok.pos}
+}
+
+func (r *Reader) readDatum(tok token) located {
+	switch tok.typ {
+	case LPAREN:
+		return r.readList(tok, RPAREN, ")")
+	case LBRACK:
+		return r.readList(tok, RBRACK, "]")
+	case VEC_OPEN:
+		items := r.readItems()
+		payload := make([]Value, len(items))
+		for i, s := range items {
+			payload[i] = s.value
+		}
+		return located{Vec{Payload: payload}, tok.pos}
+	case BYTES_OPEN:
+		items := r.readItems()
+		data := make([]byte, len(items))
+		for i, s := range items {
+			n, ok := s.value.(Int)
+			if !ok || n.Big != nil || n.Data < 0 || 255 < n.Data {
+				panic(ParseError{Msg: "Each element of #u8 must be a byte but got " + s.value.Inspect(), Pos: *s.pos})
+			}
+			data[i] = byte(n.Data)
+		}
+		return located{Bytes{Data: data}, tok.pos}
+	case QUOTE:
+		return quoted(tok, "quote", r.readDatum(r.token()))
+	case QUASIQUOTE:
+		return quoted(tok, "quasiquote", r.readDatum(r.token()))
+	case UNQUOTE:
+		return quoted(tok, "unquote", r.readDatum(r.token()))
+	case UNQUOTE_SPLICING:
+		return quoted(tok, "unquote-splicing", r.readDatum(r.token()))
+	case READER_MACRO:
+		arg := tok.val
+		if arg == nil {
+			arg = r.readDatum(r.token()).value
+		}
+		v, err := r.ReadTable[tok.str](arg)
+		if err != nil {
+			r.fail(tok, "#"+tok.str+": "+err.Error())
+		}
+		if v == nil {
+			v = Nil{}
+		}
+		return located{v, tok.pos}
+	case NUM, CHAR:
+		return located{tok.val, tok.pos}
+	case SYM:
+		return located{Sym{tok.str}, tok.pos}
+	case STR:
+		return located{Str{tok.str}, tok.pos}
+	case TRUE:
+		return located{Bool{true}, tok.pos}
+	case FALSE:
+		return located{Bool{false}, tok.pos}
+	default:
+		r.unexpected(tok)
+		return located{}
+	}
+}
+
+// Each cons cell of a list is located at its element
+func (r *Reader) readList(open token, close int, closeLit string) located {
+	var items []located
+	var tail Value = Nil{}
+	for {
+		tok := r.token()
+		if tok.typ == close {
+			break
+		}
+		if tok.typ == DOT && len(items) != 0 {
+			tail = r.readDatum(r.token()).value
+			if end := r.token(); end.typ != close {
+				r.unexpected(end)
+			}
+			break
+		}
+		if tok.typ == RPAREN || tok.typ == RBRACK || tok.typ == 0 {
+			r.fail(tok, "Expected "+closeLit+" to close "+open.lit+" at "+open.pos.String())
+		}
+		items = append(items, r.readDatum(tok))
+	}
+
+	for i := range items {
+		s := items[len(items)-1-i]
+		tail = Cons{s.value, tail, s.pos}
+	}
+	return located{tail, open.pos}
+}
+
+func (r *Reader) readItems() []located {
+	var items []located
+	for {
+		tok := r.token()
+		if tok.typ == RPAREN {
+			return items
+		}
+		items = append(items, r.readDatum(tok))
+	}
+}
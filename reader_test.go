@@ -0,0 +1,329 @@
+package golisp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func readAll(t *testing.T, r *Reader) []Value {
+	t.Helper()
+	var values []Value
+	for {
+		v, err := r.ReadValue()
+		if err == io.EOF {
+			return values
+		}
+		if err != nil {
+			t.Fatalf("ReadValue: %v", err)
+		}
+		values = append(values, v)
+	}
+}
+
+func newStringReader(src string) *Reader {
+	return NewReader(bufio.NewReader(strings.NewReader(src)))
+}
+
+func inspectAll(values []Value) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = v.Inspect()
+	}
+	return strings.Join(s, " ")
+}
+
+type readTest struct {
+	src, want string
+}
+
+// want is either the inspected values or the expected error message prefixed with "error: "
+func runReadTests(t *testing.T, tests []readTest) {
+	t.Helper()
+	for _, test := range tests {
+		r := newStringReader(test.src)
+		var values []Value
+		var got string
+		for {
+			v, err := r.ReadValue()
+			if err == io.EOF {
+				got = inspectAll(values)
+				break
+			}
+			if err != nil {
+				got = "error: " + err.Error()
+				break
+			}
+			values = append(values, v)
+		}
+		if got != test.want {
+			t.Errorf("%s\n got: %s\nwant: %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestReadBytes(t *testing.T) {
+	runReadTests(t, []readTest{
+		{`#u8(1 2 255)`, `#u8(1 2 255)`},
+		{`#u8()`, `#u8()`},
+		{`#u8( #xff 0 ; comment
+  #;1 7)`, `#u8(255 0 7)`},
+		{`'(#u8(1) . #u8(2))`, `'(#u8(1) . #u8(2))`},
+		{`#u8(256)`, `error: 1:5: Each element of #u8 must be a byte but got 256`},
+		{`#u8(-1)`, `error: 1:5: Each element of #u8 must be a byte but got -1`},
+		{`#u8(a)`, `error: 1:5: Each element of #u8 must be a byte but got a`},
+		{`#u8(1`, `error: 1:6: Unexpected end of input`},
+	})
+}
+
+func TestReadComments(t *testing.T) {
+	runReadTests(t, []readTest{
+		{"1 ; line comment\n2", `1 2`},
+		{`1 #| block |# 2`, `1 2`},
+		{`1 #| outer #| nested |# still outer |# 2`, `1 2`},
+		{"(a #| multi\nline |# b)", `(a b)`},
+		{`(a#||#b)`, `(a b)`},
+		{`#| "|#" |#`, `error: 1:7: String is not terminated`},
+		{`1 #| unterminated`, `error: 1:3: Block comment is not terminated`},
+		{`(1 #;2 3)`, `(1 3)`},
+		{`(1 #;(2 (3)) 4)`, `(1 4)`},
+		{`(1 #; #;2 3 4)`, `(1 4)`},
+		{`(1 #;'2 3)`, `(1 3)`},
+		{`#;1`, ``},
+		{`(1 #;)`, `error: 1:6: Datum comment is not followed by a datum`},
+	})
+}
+
+func TestParseAll(t *testing.T) {
+	tests := []struct {
+		src, values string
+		errs        []string
+	}{
+		{"(a) (b)", "(a) (b)", nil},
+		{"(a \"\\q\" b)\n(c)", "(c)", []string{"f:1:4: Unsupported escape sequence: \\q"}},
+		{"(a } b)\n(c) ) (d", "(c)", []string{"f:1:4: Unexpected character: }", "f:2:5: Unexpected )", "f:2:9: Expected ) to close ( at f:2:7"}},
+		{"(a (b)\n(c)", "(c)", []string{"f:2:1: Expected a closing parenthesis before the next top-level form"}},
+		{"(a #| x\n(b)", "", []string{"f:1:4: Block comment is not terminated"}},
+		{"(a . b c) #x1G (d . e)", "(d . e)", []string{"f:1:8: Unexpected c", "f:1:11: Invalid number: #x1G"}},
+		{"[a) \"x\" (b]", `"x"`, []string{"f:1:3: Expected ] to close [ at f:1:1", "f:1:11: Expected ) to close ( at f:1:9"}},
+	}
+	for _, test := range tests {
+		values, errs := ParseAll("f", bufio.NewReader(strings.NewReader(test.src)))
+		var got []string
+		for _, err := range errs {
+			got = append(got, err.Error())
+		}
+		if inspectAll(values) != test.values || strings.Join(got, "\n") != strings.Join(test.errs, "\n") {
+			t.Errorf("%s\n got: %s %q\nwant: %s %q", test.src, inspectAll(values), got, test.values, test.errs)
+		}
+	}
+}
+
+func TestReaderMacro(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`#date"2024-01-02"`, `(date "2024-01-02")`},
+		{`#date{2024-01-02}`, `(date "2024-01-02")`},
+		{`#date #date x`, `(date (date x))`},
+		{`'(1 #;#date"x" 2)`, `'(1 2)`},
+		{`'(1 #;#date #date x 2)`, `'(1 2)`},
+		{`'(1 #;#date{x} 2)`, `'(1 2)`},
+	}
+	for _, test := range tests {
+		r := newStringReader(test.src)
+		r.ReadTable = map[string]ReaderMacro{
+			"date": func(arg Value) (Value, error) { return List(Sym{"date"}, arg), nil },
+		}
+		if got := inspectAll(readAll(t, r)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestReservedReaderMacro(t *testing.T) {
+	hijack := func(Value) (Value, error) { return Sym{"hijacked"}, nil }
+	r := newStringReader(`'(#t #f #x1F #b10 #o7 #r"a" #u8(1) #custom 1)`)
+	r.ReadTable = map[string]ReaderMacro{}
+	for _, name := range []string{"t", "f", "x1F", "b10", "o7", "r", "u8", "custom"} {
+		r.ReadTable[name] = hijack
+	}
+	want := `'(#t #f 31 2 7 "a" #u8(1) hijacked)`
+	if got := inspectAll(readAll(t, r)); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestReadChar(t *testing.T) {
+	runReadTests(t, []readTest{
+		{`#\a #\Z #\λ #\日 #\( #\;`, `#\a #\Z #\λ #\日 #\( #\;`},
+		{`#\space #\newline #\tab #\nul`, `#\space #\newline #\tab #\nul`},
+		{`#\x41 #\x3bb #\x`, `#\A #\λ #\x`},
+		{`(#\a#\b)`, `(#\a #\b)`},
+		{`#\nope`, `error: 1:1: Unknown character name: nope`},
+		{`#\xD800`, `error: 1:1: Unknown character name: xD800`},
+	})
+}
+
+func TestReadStr(t *testing.T) {
+	runReadTests(t, []readTest{
+		{`"a\tb\nc\rd\0e\\f\"g"`, `"a\tb\nc\rd\0e\\f\"g"`},
+		{`"\x41;\x3bb;"`, `"Aλ"`},
+		{`"\u{1F600}\u{41}"`, `"😀A"`},
+		{"\"one \\\n    two\"", `"one two"`},
+		{"\"one \\  \r\n\ttwo\"", `"one two"`},
+		{`"\x41"`, `error: 1:1: Expected ; after \x41`},
+		{`"\u{D800}"`, `error: 1:1: Invalid code point: D800`},
+		{`"\u{110000}"`, `error: 1:1: Invalid code point: 110000`},
+		{`"\q" 1`, `error: 1:1: Unsupported escape sequence: \q`},
+		{`"\ x"`, `error: 1:1: Expected a newline after \ for line continuation`},
+		{`#r"C:\path\n"`, `"C:\\path\\n"`},
+		{`#r#"say "hi""#`, `"say \"hi\""`},
+		{`#r##"a"#b"##`, `"a\"#b"`},
+		{"#r\"multi\nline\"", `"multi\nline"`},
+		{`#r"open`, `error: 1:1: String is not terminated`},
+		{`#r'x'`, `error: 1:1: Expected " after #r`},
+	})
+}
+
+func TestReadQuotedSym(t *testing.T) {
+	runReadTests(t, []readTest{
+		{`|hello world|`, `|hello world|`},
+		{`|abc|`, `abc`},
+		{`(|a|b |c d|)`, `(a b |c d|)`},
+		{`|a\|b\\c|`, `|a\|b\\c|`},
+		{`|\x3bb;|`, `λ`},
+		{`||`, `||`},
+		{`|1| |#t| |.| |+inf.0| |quote|`, `|1| |#t| |.| |+inf.0| quote`},
+		{`'|a;b|`, `'|a;b|`},
+		{`|open`, `error: 1:1: Symbol is not terminated`},
+	})
+	for _, s := range []string{"hello world", "#sym.1", "", "a|b", "1", "-", "..."} {
+		v, err := newStringReader(Sym{s}.Inspect()).ReadValue()
+		if err != nil || !Equal(v, Sym{s}) {
+			t.Errorf("%q is printed as %s and read as %v, %v", s, Sym{s}.Inspect(), v, err)
+		}
+	}
+}
+
+func TestReadNum(t *testing.T) {
+	runReadTests(t, []readTest{
+		{`#x1F #xff #x-1a`, `31 255 -26`},
+		{`#b1010 #o17 #b-1`, `10 15 -1`},
+		{`1_000_000 1_000.000_1 #xdead_beef`, `1000000 1000.0001 3735928559`},
+		{`#x1_0000_0000_0000_0000`, `18446744073709551616`},
+		{`+inf.0 -inf.0 +nan.0 -nan.0`, `+inf.0 -inf.0 +nan.0 +nan.0`},
+		{`(inf nan +inf -nan)`, `(inf nan +inf -nan)`},
+		{`1e3 -2.5e-3 1.0`, `1000.0 -0.0025 1.0`},
+		{`010/3 -6/04 010`, `10/3 -3/2 10`},
+		{`#x1G`, `error: 1:1: Invalid number: #x1G`},
+		{`#b2`, `error: 1:1: Invalid number: #b2`},
+		{`1__0`, `error: 1:1: Invalid number: 1__0`},
+		{`_1`, `_1`},
+	})
+}
+
+func TestReadByteEscape(t *testing.T) {
+	v, err := newStringReader(`"a\u8{ff}\u8{0}"`).ReadValue()
+	if err != nil || !Equal(v, Str{"a\xff\x00"}) {
+		t.Errorf("got %v, %v", v, err)
+	}
+	if _, err := newStringReader(`"\u8{100}"`).ReadValue(); err == nil {
+		t.Errorf("\\u8{100} is accepted")
+	}
+}
+
+func TestReadConcurrently(t *testing.T) {
+	src := benchmarkSource()
+	src = src[:strings.LastIndex(src[:20000], "\n(def")+1]
+	want := inspectAll(readAll(t, newStringReader(src)))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := newStringReader(src)
+			r.ReadTable = map[string]ReaderMacro{"id": func(arg Value) (Value, error) { return arg, nil }}
+			var values []Value
+			for {
+				v, err := r.ReadValue()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				values = append(values, v)
+			}
+			if got := inspectAll(values); got != want {
+				t.Errorf("a concurrent Reader read a different program")
+			}
+			if _, errs := ParseAll("f", bufio.NewReader(strings.NewReader("(a b) (c"))); len(errs) != 1 {
+				t.Errorf("got %v", errs)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// A synthetic program with the usual mix of definitions, literals and quotes
+func benchmarkSource() string {
+	var b strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&b, `(def (f%d x . rest)
+  ; comment
+  (if (< x %d) '(a b #(1 2.5 "three") #\x #t) `+"`"+`(x ,x ,@rest))
+  [let ((s "string with \"escapes\"\n") (n 12345678901234567890) (r 1/3)) (str-concat s "!")])
+`, i, i)
+	}
+	return b.String()
+}
+
+func BenchmarkReadValue(b *testing.B) {
+	src := benchmarkSource()
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		r := NewReader(bufio.NewReader(strings.NewReader(src)))
+		for {
+			_, err := r.ReadValue()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestConsPos(t *testing.T) {
+	v, err := newStringReader("\n(a\n  (b . c) 'd)").ReadValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, _ := Slice(v)
+	tests := []struct {
+		v    Value
+		want string
+	}{
+		{v, "2:2"},
+		{v.(Cons).Cdr, "3:3"},
+		{items[1], "3:4"},
+		{v.(Cons).Cdr.(Cons).Cdr, "3:11"},
+		{items[2], "3:11"},
+		{items[2].(Cons).Cdr, "3:12"},
+	}
+	for _, test := range tests {
+		pos := test.v.(Cons).Pos()
+		if pos == nil || pos.String() != test.want {
+			t.Errorf("%s is located at %v, want %s", test.v.Inspect(), pos, test.want)
+		}
+	}
+	if pos := List(Sym{"a"}).(Cons).Pos(); pos != nil {
+		t.Errorf("a cons constructed at runtime is located at %s", pos)
+	}
+}
@@ -0,0 +1,205 @@
+package golisp
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+type RecordType struct {
+	Name   string
+	Fields []string
+}
+
+type Record struct {
+	Type   *RecordType
+	Fields []Value
+}
+
+func NewRecordType(name string, fields ...string) *RecordType {
+	return &RecordType{Name: name, Fields: fields}
+}
+
+func (t *RecordType) New(fields ...Value) (Record, error) {
+	if len(fields) != len(t.Fields) {
+		return Record{}, errors.New(t.Name + " takes " + strconv.Itoa(len(t.Fields)) + " fields")
+	}
+	return Record{Type: t, Fields: append([]Value(nil), fields...)}, nil
+}
+
+func (t *RecordType) FromStruct(v interface{}) (Record, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return Record{}, errors.New("FromStruct: " + rv.Type().String() + " is not a struct")
+	}
+	fields := make([]Value, len(t.Fields))
+	for i := range fields {
+		fields[i] = Nil{}
+	}
+	for _, field := range structFields(rv.Type()) {
+		index := t.fieldIndex(field.name)
+		if index < 0 {
+			continue
+		}
+		elem, err := toValue(rv.FieldByIndex(field.index))
+		if err != nil {
+			return Record{}, errors.New(field.name + ": " + err.Error())
+		}
+		if items, ok := Slice(elem); ok && field.vec {
+			elem = Vec{items}
+		}
+		fields[index] = elem
+	}
+	return Record{Type: t, Fields: fields}, nil
+}
+
+func (t *RecordType) fieldIndex(name string) int {
+	for i, field := range t.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *RecordType) Inspect() string {
+	return "<record-type " + t.Name + ">"
+}
+
+func (r Record) Inspect() string {
+	return r.inspect(&trail{})
+}
+
+func (r Record) inspect(t *trail) (s string) {
+	s = "#<" + r.Type.Name
+	if len(r.Fields) == 0 {
+		return s + ">"
+	}
+	p, back := t.backref(r.Fields)
+	if back {
+		return cycleMarker
+	}
+	t.within(p, func() {
+		for i, field := range r.Type.Fields {
+			s += " " + field + "=" + inspect(r.Fields[i], t)
+		}
+	})
+	return s + ">"
+}
+
+func (context *Context) DefRecord(t *RecordType) {
+	for _, binding := range t.bindings() {
+		context.toplevel.Def(binding.name, binding.value)
+	}
+}
+
+type recordBinding struct {
+	name  string
+	value Value
+}
+
+func (t *RecordType) bindings() []recordBinding {
+	bindings := []recordBinding{
+		{t.Name, t},
+		{"make-" + t.Name, builtin{recordConstructor{t}}},
+		{t.Name + "?", builtin{recordPredicate{t}}},
+	}
+	for i, field := range t.Fields {
+		bindings = append(bindings,
+			recordBinding{t.Name + "-" + field, builtin{recordAccessor{t, i}}},
+			recordBinding{"set-" + t.Name + "-" + field + "!", builtin{recordMutator{t, i}}})
+	}
+	return bindings
+}
+
+type recordConstructor struct {
+	t *RecordType
+}
+
+func (c recordConstructor) Run(state *State, args []Value) {
+	r, err := c.t.New(args...)
+	if err != nil {
+		panic(EvaluationError{Msg: "make-" + err.Error()})
+	}
+	state.Push(r)
+}
+
+type recordPredicate struct {
+	t *RecordType
+}
+
+func (p recordPredicate) Run(state *State, args []Value) {
+	if len(args) != 1 {
+		panic(EvaluationError{Msg: p.t.Name + "? takes one argument"})
+	}
+	r, ok := args[0].(Record)
+	state.Push(Bool{Data: ok && r.Type == p.t})
+}
+
+type recordAccessor struct {
+	t     *RecordType
+	index int
+}
+
+func (a recordAccessor) Run(state *State, args []Value) {
+	name := a.t.Name + "-" + a.t.Fields[a.index]
+	if len(args) != 1 {
+		panic(EvaluationError{Msg: name + " takes one argument"})
+	}
+	state.Push(a.t.take(name, args[0]).Fields[a.index])
+}
+
+type recordMutator struct {
+	t     *RecordType
+	index int
+}
+
+func (m recordMutator) Run(state *State, args []Value) {
+	name := "set-" + m.t.Name + "-" + m.t.Fields[m.index] + "!"
+	if len(args) != 2 {
+		panic(EvaluationError{Msg: name + " takes two arguments"})
+	}
+	m.t.take(name, args[0]).Fields[m.index] = args[1]
+	state.Push(Nil{})
+}
+
+func (t *RecordType) take(name string, v Value) Record {
+	r, ok := v.(Record)
+	if !ok || r.Type != t {
+		panic(EvaluationError{Msg: name + ": Expected " + t.Name + " but got " + v.Inspect()})
+	}
+	return r
+}
+
+type syntaxDefrecord struct{ noexpand }
+
+func (syntaxDefrecord) Compile(compileEnv *Env, args []Value) Code {
+	if len(args) == 2 {
+		name, ok := args[0].(Sym)
+		fields, isList := Slice(args[1])
+		if ok && isList {
+			t := &RecordType{Name: name.Data}
+			for _, field := range fields {
+				sym, ok := field.(Sym)
+				if !ok {
+					panic(EvaluationError{Msg: "Syntax error: expected field name but got " + field.Inspect()})
+				}
+				t.Fields = append(t.Fields, sym.Data)
+			}
+			var code Code
+			for _, binding := range t.bindings() {
+				code = append(code, ldc{binding.value}, def{binding.name})
+			}
+			return append(code, ldc{Nil{}})
+		}
+	}
+	panic(EvaluationError{Msg: "Syntax error: expected (defrecord name (field...))"})
+}
+
+func (r Record) entries() []Cons {
+	entries := make([]Cons, len(r.Fields))
+	for i, field := range r.Type.Fields {
+		entries[i] = Cons{Car: Sym{field}, Cdr: r.Fields[i]}
+	}
+	return entries
+}
@@ -1,6 +1,13 @@
 package golisp
 
-import "strconv"
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Num struct {
 	Data float64
@@ -14,9 +21,18 @@ type Str struct {
 	Data string
 }
 
+type Char struct {
+	Data rune
+}
+
+type Bytes struct {
+	Data []byte
+}
+
 type Cons struct {
 	Car Value
 	Cdr Value
+	pos *Pos
 }
 
 type Nil struct{}
@@ -28,7 +44,7 @@ type Bool struct {
 func List(values ...Value) Value {
 	var head Value = Nil{}
 	for i := range values {
-		head = Cons{values[len(values)-1-i], head}
+		head = Cons{Car: values[len(values)-1-i], Cdr: head}
 	}
 	return head
 }
@@ -74,23 +90,102 @@ func UnquoteSplicing(v Value) Value {
 }
 
 func (num Num) Inspect() string {
-	return strconv.FormatFloat(num.Data, 'g', -1, 64)
+	switch {
+	case math.IsInf(num.Data, 1):
+		return "+inf.0"
+	case math.IsInf(num.Data, -1):
+		return "-inf.0"
+	case math.IsNaN(num.Data):
+		return "+nan.0"
+	}
+	s := strconv.FormatFloat(num.Data, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// Distinguish inexact integers from exact ones
+		s += ".0"
+	}
+	return s
 }
 
 func (sym Sym) Inspect() string {
-	return sym.Data
+	if isPlainSym(sym.Data) {
+		return sym.Data
+	}
+	return string(append(appendStr([]byte{'|'}, sym.Data, '|'), '|'))
 }
 
 func (str Str) Inspect() string {
-	return strconv.Quote(str.Data)
+	return string(append(appendStr([]byte{'"'}, str.Data, '"'), '"'))
+}
+
+// Bytes that are not valid UTF-8 are printed as \u8{XX} so that they are read back as they are
+func appendStr(b []byte, s string, delim rune) []byte {
+	for len(s) > 0 {
+		c, size := utf8.DecodeRuneInString(s)
+		switch {
+		case c == utf8.RuneError && size == 1:
+			b = append(append(b, "\\u8{"...), strconv.FormatUint(uint64(s[0]), 16)...)
+			b = append(b, '}')
+		case c == delim:
+			b = append(b, '\\', byte(c))
+		default:
+			b = appendStrRune(b, c)
+		}
+		s = s[size:]
+	}
+	return b
+}
+
+func appendStrRune(b []byte, c rune) []byte {
+	for _, e := range strEscapes {
+		if c == e.code {
+			return append(b, '\\', byte(e.name))
+		}
+	}
+	if unicode.IsPrint(c) {
+		return utf8.AppendRune(b, c)
+	}
+	return append(append(b, "\\x"+strconv.FormatInt(int64(c), 16)...), ';')
+}
+
+func (c Char) Inspect() string {
+	for _, name := range charNames {
+		if c.Data == name.code {
+			return "#\\" + name.name
+		}
+	}
+	if unicode.IsPrint(c.Data) {
+		return "#\\" + string(c.Data)
+	}
+	return "#\\x" + strconv.FormatInt(int64(c.Data), 16)
+}
+
+func (b Bytes) Inspect() string {
+	r := "#u8("
+	for i, c := range b.Data {
+		if i != 0 {
+			r += " "
+		}
+		r += strconv.Itoa(int(c))
+	}
+	return r + ")"
+}
+
+// Pos returns the position of the element of the cons cell where it is read, or nil for conses
+// constructed at runtime
+func (cons Cons) Pos() *Pos {
+	return cons.pos
 }
 
 func (cons Cons) Inspect() string {
-	ss, ok := cons.inspectSyntaxSugar()
+	return cons.inspect(&trail{})
+}
+
+func (cons Cons) inspect(t *trail) string {
+	ss, ok := cons.inspectSyntaxSugar(t)
 	if ok {
 		return ss
 	}
-	return "(" + cons.inspectInner() + ")"
+	return "(" + cons.inspectInner(t) + ")"
 }
 
 func (Nil) Inspect() string {
@@ -105,19 +200,19 @@ func (b Bool) Inspect() string {
 	}
 }
 
-func (cons Cons) inspectSyntaxSugar() (string, bool) {
+func (cons Cons) inspectSyntaxSugar(t *trail) (string, bool) {
 	if sym, ok := cons.Car.(Sym); ok {
 		if cdr, ok := cons.Cdr.(Cons); ok {
 			if _, ok := cdr.Cdr.(Nil); ok {
 				switch sym.Data {
 				case "quote":
-					return "'" + cdr.Car.Inspect(), true
+					return "'" + inspect(cdr.Car, t), true
 				case "quasiquote":
-					return "`" + cdr.Car.Inspect(), true
+					return "`" + inspect(cdr.Car, t), true
 				case "unquote":
-					return "," + cdr.Car.Inspect(), true
+					return "," + inspect(cdr.Car, t), true
 				case "unquote-splicing":
-					return ",@" + cdr.Car.Inspect(), true
+					return ",@" + inspect(cdr.Car, t), true
 				}
 			}
 		}
@@ -125,9 +220,9 @@ func (cons Cons) inspectSyntaxSugar() (string, bool) {
 	return "", false
 }
 
-func (cons Cons) inspectInner() (r string) {
+func (cons Cons) inspectInner(t *trail) (r string) {
 	for {
-		r += cons.Car.Inspect()
+		r += inspect(cons.Car, t)
 		switch cdr := cons.Cdr.(type) {
 		case Nil:
 			return
@@ -135,8 +230,41 @@ func (cons Cons) inspectInner() (r string) {
 			r += " "
 			cons = cdr
 		default:
-			r += " . " + cons.Cdr.Inspect()
+			r += " . " + inspect(cons.Cdr, t)
 			return
 		}
 	}
 }
+
+// Containers are printed with a trail so that a container inside itself is printed as #<cycle>
+const cycleMarker = "#<cycle>"
+
+type inspector interface {
+	inspect(t *trail) string
+}
+
+// A trail remembers the containers on the path from the root
+type trail struct {
+	seen map[uintptr]bool
+}
+
+func (t *trail) backref(container interface{}) (uintptr, bool) {
+	p := reflect.ValueOf(container).Pointer()
+	return p, t.seen[p]
+}
+
+func (t *trail) within(p uintptr, f func()) {
+	if t.seen == nil {
+		t.seen = make(map[uintptr]bool)
+	}
+	t.seen[p] = true
+	f()
+	delete(t.seen, p)
+}
+
+func inspect(v Value, t *trail) string {
+	if v, ok := v.(inspector); ok {
+		return v.inspect(t)
+	}
+	return v.Inspect()
+}
@@ -0,0 +1,141 @@
+package golisp
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// anyValue generates arbitrary readable values for property-based tests
+type anyValue struct {
+	Value
+}
+
+var runePool = []rune("aZ09+-*/<=>!?$%&~^_.:@#|\\\"'()[]{};,` \t\n\r\x00\x07\x1bλ日本🍣 ​�")
+
+func randomString(rand *rand.Rand) string {
+	var b []byte
+	for n := rand.Intn(8); n > 0; n-- {
+		switch rand.Intn(8) {
+		case 0:
+			// Bytes that are not valid UTF-8
+			b = append(b, byte(0x80+rand.Intn(0x80)))
+		case 1:
+			b = append(b, string(randomRune(rand))...)
+		default:
+			b = append(b, string(runePool[rand.Intn(len(runePool))])...)
+		}
+	}
+	return string(b)
+}
+
+func randomRune(rand *rand.Rand) rune {
+	for {
+		c := rune(rand.Intn(0x110000))
+		if c < 0xd800 || 0xdfff < c {
+			return c
+		}
+	}
+}
+
+func randomNum(rand *rand.Rand) Value {
+	switch rand.Intn(8) {
+	case 0:
+		return Num{[]float64{math.Inf(1), math.Inf(-1), math.NaN(), 0, math.Copysign(0, -1)}[rand.Intn(5)]}
+	case 1:
+		return Num{float64(rand.Intn(2000) - 1000)}
+	case 2:
+		return Num{rand.NormFloat64() * math.Pow(10, float64(rand.Intn(600)-300))}
+	case 3:
+		return Int{Data: rand.Int63() - rand.Int63()}
+	case 4:
+		b := new(big.Int).Lsh(big.NewInt(rand.Int63()), uint(64+rand.Intn(100)))
+		if rand.Intn(2) == 0 {
+			b.Neg(b)
+		}
+		return MakeInt(b)
+	case 5:
+		return MakeRat(big.NewRat(rand.Int63n(2000)-1000, rand.Int63n(1000)+1))
+	default:
+		return Int{Data: int64(rand.Intn(200) - 100)}
+	}
+}
+
+func randomValue(rand *rand.Rand, depth int) Value {
+	n := 9
+	if depth > 0 {
+		n = 12
+	}
+	switch rand.Intn(n) {
+	case 0, 1:
+		return randomNum(rand)
+	case 2:
+		return Sym{randomString(rand)}
+	case 3:
+		return Sym{[]string{"quote", "quasiquote", "unquote", "unquote-splicing", ".", "+inf.0", "-nan.0", "+1", "1/2", "#t", "a.b"}[rand.Intn(11)]}
+	case 4:
+		return Str{randomString(rand)}
+	case 5:
+		return Char{randomRune(rand)}
+	case 6:
+		return Char{runePool[rand.Intn(len(runePool))]}
+	case 7:
+		return Bool{rand.Intn(2) == 0}
+	case 8:
+		b := make([]byte, rand.Intn(4))
+		rand.Read(b)
+		return Bytes{b}
+	case 9:
+		payload := make([]Value, rand.Intn(4))
+		for i := range payload {
+			payload[i] = randomValue(rand, depth-1)
+		}
+		return Vec{payload}
+	default:
+		items := make([]Value, rand.Intn(4))
+		for i := range items {
+			items[i] = randomValue(rand, depth-1)
+		}
+		if len(items) != 0 && rand.Intn(3) == 0 {
+			items[0] = Sym{[]string{"quote", "quasiquote", "unquote", "unquote-splicing"}[rand.Intn(4)]}
+		}
+		if len(items) != 0 && rand.Intn(4) == 0 {
+			return improper(items, randomValue(rand, 0))
+		}
+		return List(items...)
+	}
+}
+
+func improper(items []Value, tail Value) Value {
+	for i := range items {
+		tail = Cons{Car: items[len(items)-1-i], Cdr: tail}
+	}
+	return tail
+}
+
+func (anyValue) Generate(rand *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(anyValue{randomValue(rand, 3)})
+}
+
+func TestInspectRoundTrip(t *testing.T) {
+	roundTrip := func(v anyValue) bool {
+		src := v.Inspect()
+		r := newStringReader(src)
+		read, err := r.ReadValue()
+		if err != nil {
+			t.Logf("%s: %v", src, err)
+			return false
+		}
+		if !Equal(v.Value, read) {
+			t.Logf("%s is read as %s", src, read.Inspect())
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 5000}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,58 @@
+package stdlib
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"strings"
+
+	. "github.com/yubrot/golisp"
+)
+
+// boot.lisp is vendored from the rosetta-lisp submodule so that it is a part of this module
+//
+//go:generate cp ../golisp/rosetta-lisp/boot.lisp boot.lisp
+//go:embed boot.lisp
+var bootcode string
+
+// Boot evaluates the boot code of Rosetta Lisp embedded in this package
+func Boot(context *Context) error {
+	return BootFrom(context, strings.NewReader(bootcode))
+}
+
+// BootFrom evaluates the boot code supplied by the caller instead of the embedded one
+func BootFrom(context *Context, code io.Reader) error {
+	reader := context.NewReader("boot.lisp", bufio.NewReader(code))
+	for {
+		expr, err := reader.ReadValue()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			_, err = context.Eval(expr)
+		}
+		var denied PermissionDenied
+		if errors.As(err, &denied) {
+			err = defineDenied(context, expr, denied, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// A definition that requires a capability denied by the sandbox is bound to a stub
+// that raises the same PermissionDenied when it is called
+func defineDenied(context *Context, expr Value, denied PermissionDenied, err error) error {
+	form, ok := Slice(expr)
+	if !ok || len(form) != 3 || !Equal(form[0], Sym{Data: "def"}) {
+		return err
+	}
+	if _, ok := form[1].(Sym); !ok {
+		return err
+	}
+	stub := List(Sym{Data: "fun"}, Sym{Data: "args"}, List(Sym{Data: "builtin"}, Sym{Data: denied.Name}))
+	_, err = context.Eval(List(Sym{Data: "def"}, form[1], stub))
+	return err
+}
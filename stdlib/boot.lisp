@@ -0,0 +1,25 @@
+; local stub for development only
+(def list (fun xs xs))
+(def cons (builtin cons))
+(def car (builtin car))
+(def cdr (builtin cdr))
+(def + (builtin +))
+(def - (builtin -))
+(def * (builtin *))
+(def / (builtin /))
+(def = (builtin =))
+(def < (builtin <))
+(def error (builtin error))
+(def call/cc (builtin call/cc))
+(def vec (builtin vec))
+(def print (fun (x) ((builtin write-console) x)))
+(def append2 (fun (a b) (if ((builtin nil?) a) b (cons (car a) (append2 (cdr a) b)))))
+(def qq (fun (x)
+  (if ((builtin cons?) x)
+    (if (= (car x) 'unquote)
+      (car (cdr x))
+      (if (if ((builtin cons?) (car x)) (= (car (car x)) 'unquote-splicing) #f)
+        (list 'append2 (car (cdr (car x))) (qq (cdr x)))
+        (list 'cons (qq (car x)) (qq (cdr x)))))
+    (list 'quote x))))
+(def quasiquote (macro (x) (qq x)))
@@ -0,0 +1,59 @@
+package stdlib
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/yubrot/golisp"
+)
+
+func TestBoot(t *testing.T) {
+	context := NewContext()
+	Register(context, Options{})
+	if err := Boot(context); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := evalString(context, `(list 1 (car '(2)))`); err != nil || result.Inspect() != "(1 2)" {
+		t.Errorf("got %v, %v", result, err)
+	}
+
+	context = NewContext()
+	Register(context, Options{})
+	if err := BootFrom(context, strings.NewReader(`(def two (builtin +)) (def x (two 1 1))`)); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := evalString(context, `x`); err != nil || result.Inspect() != "2" {
+		t.Errorf("got %v, %v", result, err)
+	}
+	err := BootFrom(context, strings.NewReader(`(undefined)`))
+	if err == nil || err.Error() != "boot.lisp:1:2: Evaluation error: Undefined variable: undefined" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestBootSandboxed(t *testing.T) {
+	context := NewContext()
+	context.Capabilities = Pure
+	Register(context, Options{})
+	code := `(def exit (builtin exit)) (def plus (builtin +))`
+	if err := BootFrom(context, strings.NewReader(code)); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := evalString(context, `(plus 1 2)`); err != nil || result.Inspect() != "3" {
+		t.Errorf("got %v, %v", result, err)
+	}
+	// The stub raises the error, and the trace locates the call
+	_, err := evalString(context, `(exit 1)`)
+	var e EvaluationError
+	if !errors.As(err, new(PermissionDenied)) || !errors.As(err, &e) || e.Msg != "Permission denied: builtin exit requires process capability" {
+		t.Fatalf("got %v", err)
+	}
+	if last := e.Trace[len(e.Trace)-1]; last.Pos == nil || last.Pos.String() != "test:1:2" {
+		t.Errorf("got trace %v", e.Trace)
+	}
+	err = BootFrom(context, strings.NewReader(`((builtin exit) 1)`))
+	if !errors.As(err, new(PermissionDenied)) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,1340 @@
+package stdlib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	. "github.com/yubrot/golisp"
+	"golang.org/x/text/unicode/norm"
+)
+
+type Options struct {
+	Args []string
+}
+
+func Register(context *Context, opts Options) {
+	context.Builtins["cons"] = builtinCons{}
+
+	context.Builtins["exit"] = builtinExit{}
+	context.Builtins["error"] = builtinError{}
+
+	context.Builtins["gensym"] = &builtinGensym{}
+
+	context.Builtins["car"] = builtinCar{}
+	context.Builtins["cdr"] = builtinCdr{}
+
+	context.Builtins["apply"] = builtinApply{}
+
+	context.Builtins["num?"] = builtinTest{"num?", isNum}
+	context.Builtins["sym?"] = builtinTest{"sym?", isSym}
+	context.Builtins["str?"] = builtinTest{"str?", isStr}
+	context.Builtins["cons?"] = builtinTest{"cons?", isCons}
+	context.Builtins["nil?"] = builtinTest{"nil?", isNil}
+	context.Builtins["bool?"] = builtinTest{"bool?", isBool}
+	context.Builtins["proc?"] = builtinTest{"proc?", isProc}
+	context.Builtins["meta?"] = builtinTest{"meta?", isMeta}
+	context.Builtins["vec?"] = builtinTest{"vec?", isVec}
+	context.Builtins["char?"] = builtinTest{"char?", isChar}
+	context.Builtins["bytes?"] = builtinTest{"bytes?", isBytes}
+	context.Builtins["hash?"] = builtinTest{"hash?", isHash}
+	context.Builtins["record?"] = builtinTest{"record?", isRecord}
+
+	context.Builtins["+"] = builtinArithmetic{"+", add{}}
+	context.Builtins["-"] = builtinArithmetic{"-", sub{}}
+	context.Builtins["*"] = builtinArithmetic{"*", mul{}}
+	context.Builtins["/"] = builtinArithmetic{"/", div{}}
+	context.Builtins["%"] = builtinArithmetic{"%", mod{}}
+
+	context.Builtins["exact->inexact"] = builtinExactToInexact{}
+	context.Builtins["inexact->exact"] = builtinInexactToExact{}
+	context.Builtins["numerator"] = builtinRatPart{"numerator", (*big.Rat).Num}
+	context.Builtins["denominator"] = builtinRatPart{"denominator", (*big.Rat).Denom}
+
+	context.Builtins["="] = builtinEq{}
+	context.Builtins["equal?"] = builtinEqual{}
+	context.Builtins["hash"] = builtinHash{}
+	context.Builtins["<"] = builtinCompare{"<", lt}
+	context.Builtins[">"] = builtinCompare{">", gt}
+	context.Builtins["<="] = builtinCompare{"<=", le}
+	context.Builtins[">="] = builtinCompare{">=", ge}
+
+	context.Builtins["call/cc"] = builtinCallCC{}
+	context.Builtins["never"] = builtinNever{}
+
+	context.Builtins["char->int"] = builtinCharToInt{}
+	context.Builtins["int->char"] = builtinIntToChar{}
+	context.Builtins["char-alphabetic?"] = builtinCharTest{"char-alphabetic?", unicode.IsLetter}
+	context.Builtins["char-numeric?"] = builtinCharTest{"char-numeric?", unicode.IsDigit}
+	context.Builtins["char-whitespace?"] = builtinCharTest{"char-whitespace?", unicode.IsSpace}
+	context.Builtins["char-upper-case?"] = builtinCharTest{"char-upper-case?", unicode.IsUpper}
+	context.Builtins["char-lower-case?"] = builtinCharTest{"char-lower-case?", unicode.IsLower}
+	context.Builtins["char-upcase"] = builtinCharMap{"char-upcase", unicode.ToUpper}
+	context.Builtins["char-downcase"] = builtinCharMap{"char-downcase", unicode.ToLower}
+
+	context.Builtins["str"] = builtinStr{}
+	context.Builtins["str-ref"] = builtinStrRef{}
+	context.Builtins["str-char-at"] = builtinStrCharAt{}
+	context.Builtins["str-length"] = builtinStrLength{}
+	context.Builtins["str-concat"] = builtinStrConcat{}
+	context.Builtins["substr"] = builtinSubstr{}
+	context.Builtins["str-upcase"] = builtinStrMap{"str-upcase", strings.ToUpper}
+	context.Builtins["str-downcase"] = builtinStrMap{"str-downcase", strings.ToLower}
+	context.Builtins["str-trim"] = builtinStrMap{"str-trim", strings.TrimSpace}
+	context.Builtins["str-split"] = builtinStrSplit{}
+	context.Builtins["str-join"] = builtinStrJoin{}
+	context.Builtins["str-index-of"] = builtinStrIndexOf{}
+	context.Builtins["str-replace"] = builtinStrReplace{}
+	context.Builtins["str-starts-with?"] = builtinStrTest{"str-starts-with?", strings.HasPrefix}
+	context.Builtins["str-ends-with?"] = builtinStrTest{"str-ends-with?", strings.HasSuffix}
+	context.Builtins["str-normalize"] = builtinStrNormalize{}
+	context.Builtins["sym->str"] = builtinSymToStr{}
+	context.Builtins["str->sym"] = builtinStrToSym{}
+	context.Builtins["num->str"] = builtinNumToStr{}
+	context.Builtins["str->num"] = builtinStrToNum{}
+
+	context.Builtins["vec"] = builtinVec{}
+	context.Builtins["vec-make"] = builtinVecMake{}
+	context.Builtins["vec-length"] = builtinVecLength{}
+	context.Builtins["vec-get"] = builtinVecGet{}
+	context.Builtins["vec-set!"] = builtinVecSet{}
+	context.Builtins["vec-copy!"] = builtinVecCopy{}
+
+	context.Builtins["bytes"] = builtinBytes{}
+	context.Builtins["bytes-make"] = builtinBytesMake{}
+	context.Builtins["bytes-length"] = builtinBytesLength{}
+	context.Builtins["bytes-ref"] = builtinBytesRef{}
+	context.Builtins["bytes-set!"] = builtinBytesSet{}
+	context.Builtins["bytes-slice"] = builtinBytesSlice{}
+	context.Builtins["bytes->str"] = builtinBytesToStr{}
+	context.Builtins["str->bytes"] = builtinStrToBytes{}
+
+	context.Builtins["hash-make"] = builtinHashMake{}
+	context.Builtins["hash-get"] = builtinHashGet{}
+	context.Builtins["hash-set!"] = builtinHashSet{}
+	context.Builtins["hash-delete!"] = builtinHashDelete{}
+	context.Builtins["hash-has?"] = builtinHashHas{}
+	context.Builtins["hash-keys"] = builtinHashKeys{}
+	context.Builtins["hash-values"] = builtinHashValues{}
+	context.Builtins["hash->list"] = builtinHashToList{}
+	context.Builtins["hash-count"] = builtinHashCount{}
+
+	context.Builtins["read-file-text"] = builtinReadFileText{}
+	context.Builtins["write-file-text"] = builtinWriteFileText{}
+	context.Builtins["read-file-bytes"] = builtinReadFileBytes{}
+	context.Builtins["write-file-bytes"] = builtinWriteFileBytes{}
+	context.Builtins["read-console-line"] = builtinReadConsoleLine{}
+	context.Builtins["write-console"] = builtinWriteConsole{}
+
+	context.Builtins["args"] = builtinArgs{opts.Args}
+
+	context.Builtins["eval"] = builtinEval{}
+	context.Builtins["read-table-set!"] = builtinReadTableSet{}
+	context.Builtins["macroexpand"] = builtinMacroExpand{"macroexpand", true}
+	context.Builtins["macroexpand-1"] = builtinMacroExpand{"macroexpand-1", false}
+}
+
+type builtinCons struct{}
+
+func (builtinCons) Run(state *State, args []Value) {
+	a, b := takeTwo("cons", args)
+	state.Push(Cons{Car: a, Cdr: b})
+}
+
+type builtinExit struct{}
+
+func (builtinExit) Capability() Capability { return Process }
+
+func (builtinExit) Run(state *State, args []Value) {
+	if len(args) == 0 {
+		os.Exit(0)
+	}
+	if len(args) == 1 && IsNumber(args[0]) {
+		os.Exit(int(takeNum("exitcode", args[0])))
+	}
+	evaluationError("exit takes exitcode")
+}
+
+type builtinError struct{}
+
+func (builtinError) Run(state *State, args []Value) {
+	if len(args) == 0 {
+		evaluationError("error called")
+	}
+	if len(args) == 1 {
+		evaluationError(takeStr("error message", args[0]))
+	}
+	evaluationError("Builtin function error takes a string argument")
+}
+
+type builtinGensym struct {
+	id int
+}
+
+func (gensym *builtinGensym) Run(state *State, args []Value) {
+	takeNone("gensym", args)
+	gensym.id++
+	state.Push(Sym{Data: fmt.Sprintf("#sym.%v", gensym.id)})
+}
+
+type builtinCar struct{}
+
+func (builtinCar) Run(state *State, args []Value) {
+	arg := takeOne("car", args)
+	cons := takeCons("cons", arg)
+	state.Push(cons.Car)
+}
+
+type builtinCdr struct{}
+
+func (builtinCdr) Run(state *State, args []Value) {
+	arg := takeOne("cdr", args)
+	cons := takeCons("cons", arg)
+	state.Push(cons.Cdr)
+}
+
+type builtinApply struct{}
+
+func (builtinApply) Run(state *State, args []Value) {
+	f, fargs := takeTwo("apply", args)
+	fslice := takeList("argument list", fargs)
+	state.Apply(f, fslice...)
+}
+
+type builtinTest struct {
+	name string
+	cond func(Value) bool
+}
+
+func (test builtinTest) Run(state *State, args []Value) {
+	arg := takeOne(test.name, args)
+	state.Push(Bool{Data: test.cond(arg)})
+}
+
+func isNum(value Value) bool {
+	return IsNumber(value)
+}
+
+func isSym(value Value) bool {
+	_, ok := value.(Sym)
+	return ok
+}
+
+func isStr(value Value) bool {
+	_, ok := value.(Str)
+	return ok
+}
+
+func isCons(value Value) bool {
+	_, ok := value.(Cons)
+	return ok
+}
+
+func isNil(value Value) bool {
+	_, ok := value.(Nil)
+	return ok
+}
+
+func isBool(value Value) bool {
+	_, ok := value.(Bool)
+	return ok
+}
+
+func isProc(value Value) bool {
+	_, ok := value.(Proc)
+	return ok
+}
+
+func isMeta(value Value) bool {
+	_, ok := value.(Meta)
+	return ok
+}
+
+func isVec(value Value) bool {
+	_, ok := value.(Vec)
+	return ok
+}
+
+func isChar(value Value) bool {
+	_, ok := value.(Char)
+	return ok
+}
+
+func isBytes(value Value) bool {
+	_, ok := value.(Bytes)
+	return ok
+}
+
+func isRecord(value Value) bool {
+	_, ok := value.(Record)
+	return ok
+}
+
+func isHash(value Value) bool {
+	_, ok := value.(HashTable)
+	return ok
+}
+
+type builtinArithmetic struct {
+	name string
+	arithmeticImpl
+}
+
+func (arith builtinArithmetic) Run(state *State, args []Value) {
+	var nums []Value
+	for _, arg := range args {
+		nums = append(nums, takeNumber("number", arg))
+	}
+	var result Value
+	switch len(nums) {
+	case 0:
+		var ok bool
+		result, ok = arith.zero()
+		if !ok {
+			evaluationError(arith.name + " takes at least one argument")
+		}
+	case 1:
+		result = arith.one(nums[0])
+	default:
+		result = nums[0]
+		for _, num := range nums[1:] {
+			result = arith.fold(result, num)
+		}
+	}
+	state.Push(result)
+}
+
+type arithmeticImpl interface {
+	zero() (Value, bool)
+	one(num Value) Value
+	fold(l, r Value) Value
+}
+
+type add struct{}
+
+func (add) zero() (Value, bool)   { return Int{Data: 0}, true }
+func (add) one(num Value) Value   { return num }
+func (add) fold(l, r Value) Value { return arith(l, r, addInt, addRat, addFloat) }
+
+type sub struct{}
+
+func (sub) zero() (Value, bool)   { return Int{Data: 0}, false }
+func (sub) one(num Value) Value   { return arith(Int{Data: 0}, num, subInt, subRat, negFloat) }
+func (sub) fold(l, r Value) Value { return arith(l, r, subInt, subRat, subFloat) }
+
+type mul struct{}
+
+func (mul) zero() (Value, bool)   { return Int{Data: 1}, true }
+func (mul) one(num Value) Value   { return num }
+func (mul) fold(l, r Value) Value { return arith(l, r, mulInt, mulRat, mulFloat) }
+
+type div struct{}
+
+func (div) zero() (Value, bool)   { return Int{Data: 0}, false }
+func (div) one(num Value) Value   { return arith(Int{Data: 1}, num, divInt, divRat, divFloat) }
+func (div) fold(l, r Value) Value { return arith(l, r, divInt, divRat, divFloat) }
+
+type mod struct{}
+
+func (mod) zero() (Value, bool)   { return Int{Data: 0}, false }
+func (mod) one(num Value) Value   { return num }
+func (mod) fold(l, r Value) Value { return arith(l, r, modInt, modRat, math.Mod) }
+
+type builtinExactToInexact struct{}
+
+func (builtinExactToInexact) Run(state *State, args []Value) {
+	arg := takeOne("exact->inexact", args)
+	num := takeNum("number", arg)
+	state.Push(Num{Data: num})
+}
+
+type builtinInexactToExact struct{}
+
+func (builtinInexactToExact) Run(state *State, args []Value) {
+	arg := takeOne("inexact->exact", args)
+	num, ok := toExact(takeNumber("number", arg))
+	if !ok {
+		evaluationError("No exact representation for " + arg.Inspect())
+	}
+	state.Push(num)
+}
+
+type builtinRatPart struct {
+	name string
+	part func(*big.Rat) *big.Int
+}
+
+func (rp builtinRatPart) Run(state *State, args []Value) {
+	arg := takeOne(rp.name, args)
+	num, ok := toExact(takeNumber("number", arg))
+	if !ok {
+		evaluationError("No exact representation for " + arg.Inspect())
+	}
+	r, _ := ToRat(num)
+	result := MakeInt(new(big.Int).Set(rp.part(r)))
+	if _, inexact := arg.(Num); inexact {
+		state.Push(Num{Data: result.Float64()})
+	} else {
+		state.Push(result)
+	}
+}
+
+type builtinEq struct{}
+
+func (eq builtinEq) Run(state *State, args []Value) {
+	if len(args) >= 1 {
+		for _, arg := range args[1:] {
+			if !eq.test(args[0], arg) {
+				state.Push(Bool{Data: false})
+				return
+			}
+		}
+	}
+	state.Push(Bool{Data: true})
+}
+
+func (eq builtinEq) test(a, b Value) bool {
+	switch a := a.(type) {
+	case Num, Int, Rat:
+		return IsNumber(b) && equalNums(a, b)
+
+	case Sym:
+		b, ok := b.(Sym)
+		return ok && a.Data == b.Data
+
+	case Str:
+		b, ok := b.(Str)
+		return ok && a.Data == b.Data
+
+	case Char:
+		b, ok := b.(Char)
+		return ok && a.Data == b.Data
+
+	case Cons:
+		b, ok := b.(Cons)
+		return ok && eq.test(a.Car, b.Car) && eq.test(a.Cdr, b.Cdr)
+
+	case Bytes:
+		b, ok := b.(Bytes)
+		return ok && bytes.Equal(a.Data, b.Data)
+
+	case Record:
+		b, ok := b.(Record)
+		if !ok || a.Type != b.Type {
+			return false
+		}
+		for i := range a.Fields {
+			if !eq.test(a.Fields[i], b.Fields[i]) {
+				return false
+			}
+		}
+		return true
+
+	case Nil:
+		_, ok := b.(Nil)
+		return ok
+
+	case Bool:
+		b, ok := b.(Bool)
+		return ok && a.Data == b.Data
+
+	default:
+		return false
+	}
+}
+
+type builtinEqual struct{}
+
+func (builtinEqual) Run(state *State, args []Value) {
+	a, b := takeTwo("equal?", args)
+	state.Push(Bool{Data: Equal(a, b)})
+}
+
+type builtinHash struct{}
+
+func (builtinHash) Run(state *State, args []Value) {
+	v := takeOne("hash", args)
+	state.Push(MakeInt(new(big.Int).SetUint64(Hash(v))))
+}
+
+type builtinCompare struct {
+	name string
+	test func(compareResult int) bool
+}
+
+func (compare builtinCompare) Run(state *State, args []Value) {
+	if len(args) != 0 {
+		switch first := args[0].(type) {
+		case Num, Int, Rat:
+			var l Value = first
+			var nums []Value
+			for _, arg := range args[1:] {
+				nums = append(nums, takeNumber("number", arg))
+			}
+			for _, r := range nums {
+				if !compare.compareNumbers(l, r) {
+					state.Push(Bool{Data: false})
+					return
+				}
+				l = r
+			}
+
+		case Str:
+			l := first.Data
+			var strs []string
+			for _, arg := range args[1:] {
+				strs = append(strs, takeStr("string", arg))
+			}
+			for _, r := range strs {
+				if !compare.compareStrings(l, r) {
+					state.Push(Bool{Data: false})
+					return
+				}
+				l = r
+			}
+
+		case Char:
+			l := first.Data
+			var chars []rune
+			for _, arg := range args[1:] {
+				chars = append(chars, takeChar("character", arg))
+			}
+			for _, r := range chars {
+				if !compare.compareNumbers(Int{Data: int64(l)}, Int{Data: int64(r)}) {
+					state.Push(Bool{Data: false})
+					return
+				}
+				l = r
+			}
+
+		default:
+			evaluationError(compare.name + " is only defined for strings, characters or numbers")
+		}
+	}
+	state.Push(Bool{Data: true})
+}
+
+func (compare builtinCompare) compareNumbers(l, r Value) bool {
+	return compare.test(compareNums(l, r))
+}
+
+func (compare builtinCompare) compareStrings(l, r string) bool {
+	if l < r {
+		return compare.test(-1)
+	} else if l > r {
+		return compare.test(1)
+	} else {
+		return compare.test(0)
+	}
+}
+
+func lt(compareResult int) bool { return compareResult == -1 }
+func gt(compareResult int) bool { return compareResult == 1 }
+func le(compareResult int) bool { return compareResult != 1 }
+func ge(compareResult int) bool { return compareResult != -1 }
+
+type builtinCallCC struct{}
+
+func (builtinCallCC) Run(state *State, args []Value) {
+	f := takeOne("call/cc", args)
+	cont := state.CaptureCont()
+	state.Apply(f, cont)
+}
+
+type builtinNever struct{}
+
+func (builtinNever) Run(state *State, args []Value) {
+	if len(args) > 0 {
+		state.ApplyNever(args[0], args[1:]...)
+		return
+	}
+	evaluationError("never takes at least one argument")
+}
+
+type builtinStr struct{}
+
+func (builtinStr) Run(state *State, args []Value) {
+	var bytes []byte
+	for _, arg := range args {
+		var c rune
+		if char, ok := arg.(Char); ok {
+			c = char.Data
+		} else {
+			c = takeCodePoint("character or code point", arg)
+		}
+		bytes = utf8.AppendRune(bytes, c)
+	}
+	state.Push(Str{Data: string(bytes[:])})
+}
+
+type builtinStrRef struct{}
+
+func (builtinStrRef) Run(state *State, args []Value) {
+	str, index := takeTwo("str-ref", args)
+	s := takeStr("string", str)
+	i := takeIndex("index", index)
+	for _, c := range s {
+		if i == 0 {
+			state.Push(Char{Data: c})
+			return
+		}
+		i--
+	}
+	state.Push(Nil{})
+}
+
+type builtinCharToInt struct{}
+
+func (builtinCharToInt) Run(state *State, args []Value) {
+	arg := takeOne("char->int", args)
+	c := takeChar("character", arg)
+	state.Push(Int{Data: int64(c)})
+}
+
+type builtinIntToChar struct{}
+
+func (builtinIntToChar) Run(state *State, args []Value) {
+	arg := takeOne("int->char", args)
+	state.Push(Char{Data: takeCodePoint("code point", arg)})
+}
+
+type builtinCharTest struct {
+	name string
+	cond func(rune) bool
+}
+
+func (test builtinCharTest) Run(state *State, args []Value) {
+	arg := takeOne(test.name, args)
+	c := takeChar("character", arg)
+	state.Push(Bool{Data: test.cond(c)})
+}
+
+type builtinCharMap struct {
+	name string
+	conv func(rune) rune
+}
+
+func (m builtinCharMap) Run(state *State, args []Value) {
+	arg := takeOne(m.name, args)
+	c := takeChar("character", arg)
+	state.Push(Char{Data: m.conv(c)})
+}
+
+type builtinStrCharAt struct{}
+
+func (builtinStrCharAt) Run(state *State, args []Value) {
+	str, index := takeTwo("str-char-at", args)
+	s := []rune(takeStr("string", str))
+	i := takeIndex("index", index)
+	if len(s) <= i {
+		state.Push(Nil{})
+	} else {
+		state.Push(Int{Data: int64(s[i])})
+	}
+}
+
+type builtinStrLength struct{}
+
+func (builtinStrLength) Run(state *State, args []Value) {
+	arg := takeOne("str-length", args)
+	str := takeStr("string", arg)
+	state.Push(Int{Data: int64(utf8.RuneCountInString(str))})
+}
+
+type builtinStrConcat struct{}
+
+func (builtinStrConcat) Run(state *State, args []Value) {
+	var buf bytes.Buffer
+	for _, arg := range args {
+		buf.WriteString(takeStr("string", arg))
+	}
+	state.Push(Str{Data: buf.String()})
+}
+
+type builtinSubstr struct{}
+
+func (builtinSubstr) Run(state *State, args []Value) {
+	s, i, l := takeThree("substr", args)
+	str := []rune(takeStr("string", s))
+	index := takeIndex("index", i)
+	size := takeIndex("size", l)
+	if len(str) < index+size {
+		evaluationError("Index out of range")
+	}
+	state.Push(Str{Data: string(str[index : index+size])})
+}
+
+type builtinStrMap struct {
+	name string
+	conv func(string) string
+}
+
+func (m builtinStrMap) Run(state *State, args []Value) {
+	arg := takeOne(m.name, args)
+	str := takeStr("string", arg)
+	state.Push(Str{Data: m.conv(str)})
+}
+
+type builtinStrSplit struct{}
+
+func (builtinStrSplit) Run(state *State, args []Value) {
+	s, sep := takeTwo("str-split", args)
+	str := takeStr("string", s)
+	separator := takeStr("separator", sep)
+	var parts []Value
+	for _, part := range strings.Split(str, separator) {
+		parts = append(parts, Str{Data: part})
+	}
+	state.Push(List(parts...))
+}
+
+type builtinStrJoin struct{}
+
+func (builtinStrJoin) Run(state *State, args []Value) {
+	l, sep := takeTwo("str-join", args)
+	var strs []string
+	for _, s := range takeList("list of strings", l) {
+		strs = append(strs, takeStr("string", s))
+	}
+	separator := takeStr("separator", sep)
+	state.Push(Str{Data: strings.Join(strs, separator)})
+}
+
+type builtinStrIndexOf struct{}
+
+func (builtinStrIndexOf) Run(state *State, args []Value) {
+	s, sub := takeTwo("str-index-of", args)
+	str := takeStr("string", s)
+	i := strings.Index(str, takeStr("substring", sub))
+	if i < 0 {
+		state.Push(Nil{})
+	} else {
+		state.Push(Int{Data: int64(utf8.RuneCountInString(str[:i]))})
+	}
+}
+
+type builtinStrReplace struct{}
+
+func (builtinStrReplace) Run(state *State, args []Value) {
+	s, o, n := takeThree("str-replace", args)
+	str := takeStr("string", s)
+	old := takeStr("old substring", o)
+	new := takeStr("new substring", n)
+	state.Push(Str{Data: strings.ReplaceAll(str, old, new)})
+}
+
+type builtinStrTest struct {
+	name string
+	cond func(s, t string) bool
+}
+
+func (test builtinStrTest) Run(state *State, args []Value) {
+	s, t := takeTwo(test.name, args)
+	state.Push(Bool{Data: test.cond(takeStr("string", s), takeStr("string", t))})
+}
+
+type builtinStrNormalize struct{}
+
+func (builtinStrNormalize) Run(state *State, args []Value) {
+	s, f := takeTwo("str-normalize", args)
+	str := takeStr("string", s)
+	var form norm.Form
+	switch takeSym("normalization form", f) {
+	case "nfc":
+		form = norm.NFC
+	case "nfd":
+		form = norm.NFD
+	case "nfkc":
+		form = norm.NFKC
+	case "nfkd":
+		form = norm.NFKD
+	default:
+		evaluationError("Unknown normalization form: " + f.Inspect())
+	}
+	state.Push(Str{Data: form.String(str)})
+}
+
+type builtinSymToStr struct{}
+
+func (builtinSymToStr) Run(state *State, args []Value) {
+	arg := takeOne("sym->str", args)
+	s := takeSym("symbol", arg)
+	state.Push(Str{Data: s})
+}
+
+type builtinStrToSym struct{}
+
+func (builtinStrToSym) Run(state *State, args []Value) {
+	arg := takeOne("str->sym", args)
+	str := takeStr("string", arg)
+	state.Push(Sym{Data: str})
+}
+
+type builtinNumToStr struct{}
+
+func (builtinNumToStr) Run(state *State, args []Value) {
+	arg := takeOne("num->str", args)
+	n := takeNumber("number", arg)
+	state.Push(Str{Data: n.Inspect()})
+}
+
+type builtinStrToNum struct{}
+
+func (builtinStrToNum) Run(state *State, args []Value) {
+	arg := takeOne("str->num", args)
+	s := takeStr("string", arg)
+	num, err := ParseNum(s)
+	if err != nil {
+		state.Push(Nil{})
+	} else {
+		state.Push(num)
+	}
+}
+
+type builtinVec struct{}
+
+func (builtinVec) Run(state *State, args []Value) {
+	state.Push(Vec{Payload: args})
+}
+
+type builtinVecMake struct{}
+
+func (builtinVecMake) Run(state *State, args []Value) {
+	l, init := takeTwo("vec-make", args)
+	length := takeIndex("length", l)
+	slice := make([]Value, length)
+	for i := range slice {
+		slice[i] = init
+	}
+	state.Push(Vec{Payload: slice})
+}
+
+type builtinVecLength struct{}
+
+func (builtinVecLength) Run(state *State, args []Value) {
+	v := takeOne("vec-length", args)
+	vec := takeVec("vector", v)
+	state.Push(Int{Data: int64(len(vec.Payload))})
+}
+
+type builtinVecGet struct{}
+
+func (builtinVecGet) Run(state *State, args []Value) {
+	v, n := takeTwo("vec-get", args)
+	vec := takeVec("vector", v)
+	index := takeIndex("index", n)
+	if len(vec.Payload) <= index {
+		state.Push(Nil{})
+	} else {
+		state.Push(vec.Payload[index])
+	}
+}
+
+type builtinVecSet struct{}
+
+func (builtinVecSet) Run(state *State, args []Value) {
+	v, n, item := takeThree("vec-set!", args)
+	vec := takeVec("vector", v)
+	index := takeIndex("index", n)
+	if len(vec.Payload) <= index {
+		evaluationError("Index out of range")
+	} else {
+		vec.Payload[index] = item
+		state.Push(Nil{})
+	}
+}
+
+type builtinVecCopy struct{}
+
+func (builtinVecCopy) Run(state *State, args []Value) {
+	dest, destS, src, srcS, l := takeFive("vec-copy!", args)
+	destVec := takeVec("destination vector", dest)
+	destStart := takeIndex("destination index", destS)
+	srcVec := takeVec("source vector", src)
+	srcStart := takeIndex("source index", srcS)
+	length := takeIndex("length", l)
+
+	if srcStart+length <= len(srcVec.Payload) && destStart+length <= len(destVec.Payload) {
+		copy(destVec.Payload[destStart:], srcVec.Payload[srcStart:srcStart+length])
+		state.Push(Nil{})
+	} else {
+		evaluationError("Index out of range")
+	}
+}
+
+type builtinBytes struct{}
+
+func (builtinBytes) Run(state *State, args []Value) {
+	data := make([]byte, len(args))
+	for i, arg := range args {
+		data[i] = takeByte("byte", arg)
+	}
+	state.Push(Bytes{Data: data})
+}
+
+type builtinBytesMake struct{}
+
+func (builtinBytesMake) Run(state *State, args []Value) {
+	l, init := takeTwo("bytes-make", args)
+	length := takeIndex("length", l)
+	b := takeByte("byte", init)
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = b
+	}
+	state.Push(Bytes{Data: data})
+}
+
+type builtinBytesLength struct{}
+
+func (builtinBytesLength) Run(state *State, args []Value) {
+	b := takeOne("bytes-length", args)
+	data := takeBytes("bytes", b)
+	state.Push(Int{Data: int64(len(data.Data))})
+}
+
+type builtinBytesRef struct{}
+
+func (builtinBytesRef) Run(state *State, args []Value) {
+	b, n := takeTwo("bytes-ref", args)
+	data := takeBytes("bytes", b)
+	index := takeIndex("index", n)
+	if len(data.Data) <= index {
+		state.Push(Nil{})
+	} else {
+		state.Push(Int{Data: int64(data.Data[index])})
+	}
+}
+
+type builtinBytesSet struct{}
+
+func (builtinBytesSet) Run(state *State, args []Value) {
+	b, n, v := takeThree("bytes-set!", args)
+	data := takeBytes("bytes", b)
+	index := takeIndex("index", n)
+	if len(data.Data) <= index {
+		evaluationError("Index out of range")
+	}
+	data.Data[index] = takeByte("byte", v)
+	state.Push(Nil{})
+}
+
+type builtinBytesSlice struct{}
+
+func (builtinBytesSlice) Run(state *State, args []Value) {
+	b, i, l := takeThree("bytes-slice", args)
+	data := takeBytes("bytes", b)
+	index := takeIndex("index", i)
+	size := takeIndex("size", l)
+	if len(data.Data) < index+size {
+		evaluationError("Index out of range")
+	}
+	state.Push(Bytes{Data: append([]byte(nil), data.Data[index:index+size]...)})
+}
+
+type builtinBytesToStr struct{}
+
+func (builtinBytesToStr) Run(state *State, args []Value) {
+	b := takeOne("bytes->str", args)
+	data := takeBytes("bytes", b)
+	if !utf8.Valid(data.Data) {
+		evaluationError("Invalid UTF-8 sequence")
+	}
+	state.Push(Str{Data: string(data.Data)})
+}
+
+type builtinStrToBytes struct{}
+
+func (builtinStrToBytes) Run(state *State, args []Value) {
+	s := takeOne("str->bytes", args)
+	str := takeStr("string", s)
+	state.Push(Bytes{Data: []byte(str)})
+}
+
+type builtinHashMake struct{}
+
+func (builtinHashMake) Run(state *State, args []Value) {
+	table := NewHashTable()
+	for _, entry := range args {
+		pair, ok := entry.(Cons)
+		checkExpected("key-value pair", ok, entry)
+		table.Set(pair.Car, pair.Cdr)
+	}
+	state.Push(table)
+}
+
+type builtinHashGet struct{}
+
+func (builtinHashGet) Run(state *State, args []Value) {
+	var fallback Value = Nil{}
+	if len(args) == 3 {
+		fallback = args[2]
+		args = args[:2]
+	}
+	h, key := takeTwo("hash-get", args)
+	table := takeHash("hash table", h)
+	value, ok := table.Get(key)
+	if ok {
+		state.Push(value)
+	} else {
+		state.Push(fallback)
+	}
+}
+
+type builtinHashSet struct{}
+
+func (builtinHashSet) Run(state *State, args []Value) {
+	h, key, value := takeThree("hash-set!", args)
+	table := takeHash("hash table", h)
+	table.Set(key, value)
+	state.Push(Nil{})
+}
+
+type builtinHashDelete struct{}
+
+func (builtinHashDelete) Run(state *State, args []Value) {
+	h, key := takeTwo("hash-delete!", args)
+	table := takeHash("hash table", h)
+	table.Delete(key)
+	state.Push(Nil{})
+}
+
+type builtinHashHas struct{}
+
+func (builtinHashHas) Run(state *State, args []Value) {
+	h, key := takeTwo("hash-has?", args)
+	table := takeHash("hash table", h)
+	_, ok := table.Get(key)
+	state.Push(Bool{Data: ok})
+}
+
+type builtinHashKeys struct{}
+
+func (builtinHashKeys) Run(state *State, args []Value) {
+	h := takeOne("hash-keys", args)
+	table := takeHash("hash table", h)
+	var keys []Value
+	for _, entry := range table.Entries() {
+		keys = append(keys, entry.Car)
+	}
+	state.Push(List(keys...))
+}
+
+type builtinHashValues struct{}
+
+func (builtinHashValues) Run(state *State, args []Value) {
+	h := takeOne("hash-values", args)
+	table := takeHash("hash table", h)
+	var values []Value
+	for _, entry := range table.Entries() {
+		values = append(values, entry.Cdr)
+	}
+	state.Push(List(values...))
+}
+
+type builtinHashToList struct{}
+
+func (builtinHashToList) Run(state *State, args []Value) {
+	h := takeOne("hash->list", args)
+	table := takeHash("hash table", h)
+	var entries []Value
+	for _, entry := range table.Entries() {
+		entries = append(entries, entry)
+	}
+	state.Push(List(entries...))
+}
+
+type builtinHashCount struct{}
+
+func (builtinHashCount) Run(state *State, args []Value) {
+	h := takeOne("hash-count", args)
+	table := takeHash("hash table", h)
+	state.Push(Int{Data: int64(table.Len())})
+}
+
+type builtinReadFileText struct{}
+
+func (builtinReadFileText) Capability() Capability { return IORead }
+
+func (builtinReadFileText) Run(state *State, args []Value) {
+	p := takeOne("read-file-text", args)
+	filepath := takeStr("filepath", p)
+	contents, err := os.ReadFile(filepath)
+	if err == nil {
+		state.Push(Cons{Car: Bool{Data: true}, Cdr: Str{Data: string(contents)}})
+	} else {
+		state.Push(Cons{Car: Bool{Data: false}, Cdr: Str{Data: err.Error()}})
+	}
+}
+
+type builtinWriteFileText struct{}
+
+func (builtinWriteFileText) Capability() Capability { return IOWrite }
+
+func (builtinWriteFileText) Run(state *State, args []Value) {
+	p, c := takeTwo("write-file-text", args)
+	filepath := takeStr("filepath", p)
+	contents := takeStr("contents", c)
+	err := os.WriteFile(filepath, []byte(contents), 0666)
+	if err == nil {
+		state.Push(Cons{Car: Bool{Data: true}, Cdr: Nil{}})
+	} else {
+		state.Push(Cons{Car: Bool{Data: false}, Cdr: Str{Data: err.Error()}})
+	}
+}
+
+type builtinReadFileBytes struct{}
+
+func (builtinReadFileBytes) Capability() Capability { return IORead }
+
+func (builtinReadFileBytes) Run(state *State, args []Value) {
+	p := takeOne("read-file-bytes", args)
+	filepath := takeStr("filepath", p)
+	contents, err := os.ReadFile(filepath)
+	if err == nil {
+		state.Push(Cons{Car: Bool{Data: true}, Cdr: Bytes{Data: contents}})
+	} else {
+		state.Push(Cons{Car: Bool{Data: false}, Cdr: Str{Data: err.Error()}})
+	}
+}
+
+type builtinWriteFileBytes struct{}
+
+func (builtinWriteFileBytes) Capability() Capability { return IOWrite }
+
+func (builtinWriteFileBytes) Run(state *State, args []Value) {
+	p, c := takeTwo("write-file-bytes", args)
+	filepath := takeStr("filepath", p)
+	contents := takeBytes("contents", c)
+	err := os.WriteFile(filepath, contents.Data, 0666)
+	if err == nil {
+		state.Push(Cons{Car: Bool{Data: true}, Cdr: Nil{}})
+	} else {
+		state.Push(Cons{Car: Bool{Data: false}, Cdr: Str{Data: err.Error()}})
+	}
+}
+
+type builtinReadConsoleLine struct{}
+
+func (builtinReadConsoleLine) Capability() Capability { return Console }
+
+func (builtinReadConsoleLine) Run(state *State, args []Value) {
+	takeNone("read-console-line", args)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		state.Push(Cons{Car: Bool{Data: true}, Cdr: Str{Data: string(scanner.Text())}})
+	} else if scanner.Err() == nil {
+		state.Push(Cons{Car: Bool{Data: true}, Cdr: Nil{}})
+	} else {
+		state.Push(Cons{Car: Bool{Data: false}, Cdr: Str{Data: scanner.Err().Error()}})
+	}
+}
+
+type builtinWriteConsole struct{}
+
+func (builtinWriteConsole) Capability() Capability { return Console }
+
+func (builtinWriteConsole) Run(state *State, args []Value) {
+	s := takeOne("write-console", args)
+	text := takeStr("text", s)
+	_, err := fmt.Print(text)
+	if err == nil {
+		state.Push(Cons{Car: Bool{Data: true}, Cdr: Nil{}})
+	} else {
+		state.Push(Cons{Car: Bool{Data: false}, Cdr: Str{Data: err.Error()}})
+	}
+}
+
+type builtinArgs struct {
+	args []string
+}
+
+func (builtinArgs) Capability() Capability { return Process }
+
+func (b builtinArgs) Run(state *State, args []Value) {
+	takeNone("args", args)
+	var vs []Value
+	for _, arg := range b.args {
+		vs = append(vs, Str{Data: arg})
+	}
+	state.Push(List(vs...))
+}
+
+type builtinReadTableSet struct{}
+
+func (builtinReadTableSet) Run(state *State, args []Value) {
+	n, f := takeTwo("read-table-set!", args)
+	name := takeSym("name", n)
+	_, ok := f.(Proc)
+	checkExpected("procedure", ok, f)
+	if IsReservedReaderMacro(name) {
+		evaluationError("read-table-set!: #" + name + " is reserved")
+	}
+	context := state.Context
+	context.ReadTable[name] = func(arg Value) (Value, error) {
+		return context.Call(f, arg)
+	}
+	state.Push(Nil{})
+}
+
+type builtinEval struct{}
+
+func (builtinEval) Run(state *State, args []Value) {
+	if len(args) == 1 {
+		ret, err := state.Context.Eval(args[0])
+		if err == nil {
+			state.Push(Cons{Car: Bool{Data: true}, Cdr: ret})
+		} else {
+			state.Push(Cons{Car: Bool{Data: false}, Cdr: Str{Data: err.Error()}})
+		}
+		return
+	}
+	evaluationError("Builtin function eval takes one argument")
+}
+
+type builtinMacroExpand struct {
+	name    string
+	recurse bool
+}
+
+func (expand builtinMacroExpand) Run(state *State, args []Value) {
+	if len(args) == 1 {
+		ret, err := state.Context.MacroExpand(expand.recurse, args[0])
+		if err == nil {
+			state.Push(Cons{Car: Bool{Data: true}, Cdr: ret})
+		} else {
+			state.Push(Cons{Car: Bool{Data: false}, Cdr: Str{Data: err.Error()}})
+		}
+		return
+	}
+	evaluationError("Builtin function " + expand.name + " takes one argument")
+}
+
+func takeNone(name string, args []Value) {
+	if len(args) != 0 {
+		evaluationError(name + " takes no arguments")
+	}
+}
+
+func takeOne(name string, args []Value) Value {
+	if len(args) != 1 {
+		evaluationError(name + " takes one argument")
+	}
+	return args[0]
+}
+
+func takeTwo(name string, args []Value) (Value, Value) {
+	if len(args) != 2 {
+		evaluationError(name + " takes two arguments")
+	}
+	return args[0], args[1]
+}
+
+func takeThree(name string, args []Value) (Value, Value, Value) {
+	if len(args) != 3 {
+		evaluationError(name + " takes three arguments")
+	}
+	return args[0], args[1], args[2]
+}
+
+func takeFive(name string, args []Value) (Value, Value, Value, Value, Value) {
+	if len(args) != 5 {
+		evaluationError(name + " takes five arguments")
+	}
+	return args[0], args[1], args[2], args[3], args[4]
+}
+
+func takeNum(name string, v Value) float64 {
+	ret, ok := ToFloat(v)
+	checkExpected(name, ok, v)
+	return ret
+}
+
+func takeNumber(name string, v Value) Value {
+	checkExpected(name, IsNumber(v), v)
+	return v
+}
+
+func takeSym(name string, v Value) string {
+	ret, ok := v.(Sym)
+	checkExpected(name, ok, v)
+	return ret.Data
+}
+
+func takeStr(name string, v Value) string {
+	ret, ok := v.(Str)
+	checkExpected(name, ok, v)
+	return ret.Data
+}
+
+func takeChar(name string, v Value) rune {
+	ret, ok := v.(Char)
+	checkExpected(name, ok, v)
+	return ret.Data
+}
+
+func takeCons(name string, v Value) Cons {
+	ret, ok := v.(Cons)
+	checkExpected(name, ok, v)
+	return ret
+}
+
+func takeList(name string, v Value) []Value {
+	ret, ok := Slice(v)
+	checkExpected(name, ok, v)
+	return ret
+}
+
+func takeBytes(name string, v Value) Bytes {
+	ret, ok := v.(Bytes)
+	checkExpected(name, ok, v)
+	return ret
+}
+
+func takeByte(name string, v Value) byte {
+	n, ok := v.(Int)
+	checkExpected(name, ok && n.Big == nil && 0 <= n.Data && n.Data <= 255, v)
+	return byte(n.Data)
+}
+
+// Indices and sizes are bounded so that their sums cannot overflow
+func takeIndex(name string, v Value) int {
+	n, ok := v.(Int)
+	checkExpected(name, ok && n.Big == nil && 0 <= n.Data && n.Data <= math.MaxInt32, v)
+	return int(n.Data)
+}
+
+func takeCodePoint(name string, v Value) rune {
+	n, ok := v.(Int)
+	checkExpected(name, ok && n.Big == nil && 0 <= n.Data && n.Data <= unicode.MaxRune && utf8.ValidRune(rune(n.Data)), v)
+	return rune(n.Data)
+}
+
+func takeHash(name string, v Value) HashTable {
+	ret, ok := v.(HashTable)
+	checkExpected(name, ok, v)
+	return ret
+}
+
+func takeVec(name string, v Value) Vec {
+	ret, ok := v.(Vec)
+	checkExpected(name, ok, v)
+	return ret
+}
+
+func checkExpected(name string, ok bool, v Value) {
+	if !ok {
+		evaluationError("Expected " + name + " but got " + v.Inspect())
+	}
+}
+
+func evaluationError(msg string) {
+	panic(EvaluationError{Msg: msg})
+}
@@ -0,0 +1,186 @@
+package stdlib
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/yubrot/golisp"
+)
+
+// Every builtin is bound to its name, as boot.lisp does for most of them
+func newContext() *Context {
+	context := NewContext()
+	Register(context, Options{})
+	for name := range context.Builtins {
+		_, err := context.Eval(List(Sym{Data: "def"}, Sym{Data: name}, List(Sym{Data: "builtin"}, Sym{Data: name})))
+		if err != nil {
+			panic(err)
+		}
+	}
+	return context
+}
+
+func evalString(context *Context, src string) (result Value, err error) {
+	reader := context.NewReader("test", bufio.NewReader(strings.NewReader(src)))
+	for {
+		expr, err := reader.ReadValue()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err == nil {
+			result, err = context.Eval(expr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+type evalTest struct {
+	src, want string
+}
+
+// want is either the inspected result or the expected error message prefixed with "error: "
+func runEvalTests(t *testing.T, tests []evalTest) {
+	t.Helper()
+	for _, test := range tests {
+		result, err := evalString(newContext(), test.src)
+		var got string
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = result.Inspect()
+		}
+		if got != test.want {
+			t.Errorf("%s\n got: %s\nwant: %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestReadTableSet(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{`(read-table-set! 'twice (fun (x) (cons x x))) '#twice 1`, `(1 . 1)`},
+		{`(read-table-set! 'twice (fun (x) (cons x x))) '(#t #twice{a})`, `(#t ("a" . "a"))`},
+		{`(read-table-set! 't (fun (x) 'hijacked))`, `error: test:1:2: Evaluation error: read-table-set!: #t is reserved`},
+		{`(read-table-set! 'u8 (fun (x) 'hijacked))`, `error: test:1:2: Evaluation error: read-table-set!: #u8 is reserved`},
+		{`(read-table-set! 'x1F (fun (x) 'hijacked))`, `error: test:1:2: Evaluation error: read-table-set!: #x1F is reserved`},
+		{`(read-table-set! 'xmas (fun (x) 'hijacked)) '#xmas 1`, `hijacked`},
+	})
+}
+
+func TestHashTable(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{`(def h (hash-make '(b . 1) '(a . 2) '(c . 3))) (hash-set! h 'a 4) (hash->list h)`, `((b . 1) (a . 4) (c . 3))`},
+		{`(def h (hash-make '(b . 1) '(a . 2) '(c . 3))) (hash-delete! h 'b) (hash-set! h 'b 5) (hash-keys h)`, `(a c b)`},
+		{`(def h (hash-make)) (hash-set! h h 1) (hash-set! h 'a 2) (hash-keys h)`, `(#hash((#<cycle> . 1) (a . 2)) a)`},
+		{`(def v (vec-make 12 0)) (vec-set! v 0 v) (def h (hash-make)) (hash-set! h v 1) (hash-get h v)`, `1`},
+		{`(def v (vec 1 2)) (vec-set! v 1 v) v`, `#(1 #<cycle>)`},
+	})
+}
+
+func TestChars(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{`#\a`, `#\a`},
+		{`(vec #\space #\newline #\x3bb #\λ)`, `#(#\space #\newline #\λ #\λ)`},
+		{`(vec (char? #\a) (char? "a") (char? 97))`, `#(#t #f #f)`},
+		{`(char->int #\x1F600)`, `128512`},
+		{`(int->char 955)`, `#\λ`},
+		{`(int->char 97.9)`, `error: test:1:2: Evaluation error: Expected code point but got 97.9`},
+		{`(int->char 1/2)`, `error: test:1:2: Evaluation error: Expected code point but got 1/2`},
+		{`(int->char -1)`, `error: test:1:2: Evaluation error: Expected code point but got -1`},
+		{`(int->char #xD800)`, `error: test:1:2: Evaluation error: Expected code point but got 55296`},
+		{`(int->char #x110000)`, `error: test:1:2: Evaluation error: Expected code point but got 1114112`},
+		{`(str #\日 #\本 26085)`, `"日本日"`},
+		{`(str 97.5)`, `error: test:1:2: Evaluation error: Expected character or code point but got 97.5`},
+		{`(vec (str-ref "日本語" 1) (str-ref "日本語" 3))`, `#(#\本 ())`},
+		{`(vec (char-upcase #\ß) (char-upcase #\λ) (char-alphabetic? #\λ) (char-numeric? #\٣))`, `#(#\ß #\Λ #t #t)`},
+	})
+}
+
+func TestBytes(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{`(bytes 1 2 3)`, `#u8(1 2 3)`},
+		{`(bytes-make 3 7)`, `#u8(7 7 7)`},
+		{`(def b (bytes-make 2 0)) (bytes-set! b 1 255) b`, `#u8(0 255)`},
+		{`(vec (bytes-ref #u8(4 5) 1) (bytes-length #u8(4 5)) (bytes? #u8()) (bytes? "s"))`, `#(5 2 #t #f)`},
+		{`(bytes-slice #u8(1 2 3 4) 1 2)`, `#u8(2 3)`},
+		{`(bytes-slice #u8(1 2 3 4) 3 2)`, `error: test:1:2: Evaluation error: Index out of range`},
+		{`(vec (bytes->str #u8(206 187)) (str->bytes "λ"))`, `#("λ" #u8(206 187))`},
+		{`(equal? #u8(1 2) (bytes 1 2))`, `#t`},
+		{`(bytes 256)`, `error: test:1:2: Evaluation error: Expected byte but got 256`},
+		{`(bytes-ref #u8(1) 1)`, `()`},
+	})
+}
+
+func TestStrings(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{`(str-length "")`, `0`},
+		{`(str-length "héllo")`, `5`},
+		{`(str-length "日本語")`, `3`},
+		{`(str-length "👍🏽")`, `2`},
+		{`(str-ref "日本語" 0)`, `#\日`},
+		{`(str-ref "日本語" 2)`, `#\語`},
+		{`(str-ref "日本語" 3)`, `()`},
+		{`(str-ref "" 0)`, `()`},
+		{`(str-ref "abc" 1.5)`, `error: test:1:2: Evaluation error: Expected index but got 1.5`},
+		{`(str-ref "abc" -1)`, `error: test:1:2: Evaluation error: Expected index but got -1`},
+		{`(str-ref "abc" 1/2)`, `error: test:1:2: Evaluation error: Expected index but got 1/2`},
+		{`(str-char-at "añb" 1)`, `241`},
+		{`(str-char-at "añb" 3)`, `()`},
+		{`(substr "日本語" 1 2)`, `"本語"`},
+		{`(substr "日本語" 3 0)`, `""`},
+		{`(substr "日本語" 0 3)`, `"日本語"`},
+		{`(substr "日本語" 2 2)`, `error: test:1:2: Evaluation error: Index out of range`},
+		{`(substr "abc" 0.5 1)`, `error: test:1:2: Evaluation error: Expected index but got 0.5`},
+		{`(substr "abc" 0 -1)`, `error: test:1:2: Evaluation error: Expected size but got -1`},
+		{`(substr "abc" 99999999999999999999 1)`, `error: test:1:2: Evaluation error: Expected index but got 99999999999999999999`},
+		{`(str-upcase "straße")`, `"STRAßE"`},
+		{`(str-downcase "ÀÉÎ")`, `"àéî"`},
+		{`(str-trim "　 日本\t\n")`, `"日本"`},
+		{`(str-upcase 'a)`, `error: test:1:2: Evaluation error: Expected string but got a`},
+		{`(str-split "α,β,,γ" ",")`, `("α" "β" "" "γ")`},
+		{`(str-split "" ",")`, `("")`},
+		{`(str-split "日本" "")`, `("日" "本")`},
+		{`(str-split "a→b→c" "→")`, `("a" "b" "c")`},
+		{`(str-join '("α" "β" "γ") "・")`, `"α・β・γ"`},
+		{`(str-join '() ",")`, `""`},
+		{`(str-join '("a" b) ",")`, `error: test:1:2: Evaluation error: Expected string but got b`},
+		{`(str-index-of "日本語" "語")`, `2`},
+		{`(str-index-of "日本語" "")`, `0`},
+		{`(str-index-of "日本語" "英")`, `()`},
+		{`(str-index-of "éé" "é")`, `0`},
+		{`(str-replace "日本語の日本" "日本" "にほん")`, `"にほん語のにほん"`},
+		{`(str-replace "ab" "" "-")`, `"-a-b-"`},
+		{`(str-starts-with? "日本語" "日本")`, `#t`},
+		{`(str-starts-with? "日本語" "本")`, `#f`},
+		{`(str-ends-with? "日本語" "語")`, `#t`},
+		{`(str-ends-with? "" "")`, `#t`},
+		{`(str-length (str-normalize "é" 'nfc))`, `1`},
+		{`(str-length (str-normalize "é" 'nfd))`, `2`},
+		{`(str-normalize "ｶ" 'nfkc)`, `"カ"`},
+		{`(equal? (str-normalize "é" 'nfkd) (str-normalize "é" 'nfd))`, `#t`},
+		{`(str-normalize "a" 'nfx)`, `error: test:1:2: Evaluation error: Unknown normalization form: nfx`},
+	})
+}
+
+func TestIndices(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{`(vec-get (vec 1 2 3) 2)`, `3`},
+		{`(vec-get (vec 1 2 3) 3)`, `()`},
+		{`(vec-get (vec 1 2 3) 1.9)`, `error: test:1:2: Evaluation error: Expected index but got 1.9`},
+		{`(vec-set! (vec 1 2 3) 3 0)`, `error: test:1:2: Evaluation error: Index out of range`},
+		{`(vec-set! (vec 1 2 3) -1 0)`, `error: test:1:2: Evaluation error: Expected index but got -1`},
+		{`(vec-make 2 0)`, `#(0 0)`},
+		{`(vec-make -1 0)`, `error: test:1:2: Evaluation error: Expected length but got -1`},
+		{`(def v (vec 1 2 3)) (vec-copy! v 0 (vec 4 5) 0 2) v`, `#(4 5 3)`},
+		{`(vec-copy! (vec 1 2 3) 2 (vec 4 5) 0 2)`, `error: test:1:2: Evaluation error: Index out of range`},
+		{`(vec-copy! (vec 1 2 3) 0 (vec 4 5) 0 2.0)`, `error: test:1:2: Evaluation error: Expected length but got 2.0`},
+		{`(bytes-ref #u8(1 2) 1)`, `2`},
+		{`(bytes-ref #u8(1 2) 2)`, `()`},
+		{`(bytes-slice #u8(1 2 3) 1 2)`, `#u8(2 3)`},
+		{`(bytes-slice #u8(1 2 3) 2 2)`, `error: test:1:2: Evaluation error: Index out of range`},
+		{`(bytes-make -1 0)`, `error: test:1:2: Evaluation error: Expected length but got -1`},
+	})
+}
@@ -0,0 +1,174 @@
+package stdlib
+
+import (
+	"math"
+	"math/big"
+
+	. "github.com/yubrot/golisp"
+)
+
+type intOp func(l, r Int) (Value, bool)
+type ratOp func(l, r *big.Rat) (Value, bool)
+type floatOp func(l, r float64) float64
+
+func arith(l, r Value, iop intOp, rop ratOp, fop floatOp) Value {
+	if a, ok := l.(Int); ok {
+		if b, ok := r.(Int); ok {
+			if v, ok := iop(a, b); ok {
+				return v
+			}
+		}
+	}
+	if a, ok := ToRat(l); ok {
+		if b, ok := ToRat(r); ok {
+			if v, ok := rop(a, b); ok {
+				return v
+			}
+		}
+	}
+	a, _ := ToFloat(l)
+	b, _ := ToFloat(r)
+	return Num{Data: fop(a, b)}
+}
+
+func addFloat(l, r float64) float64 { return l + r }
+func subFloat(l, r float64) float64 { return l - r }
+func negFloat(_, r float64) float64 { return -r }
+func mulFloat(l, r float64) float64 { return l * r }
+func divFloat(l, r float64) float64 { return l / r }
+
+func addRat(l, r *big.Rat) (Value, bool) { return MakeRat(l.Add(l, r)), true }
+func subRat(l, r *big.Rat) (Value, bool) { return MakeRat(l.Sub(l, r)), true }
+func mulRat(l, r *big.Rat) (Value, bool) { return MakeRat(l.Mul(l, r)), true }
+
+func divRat(l, r *big.Rat) (Value, bool) {
+	if r.Sign() == 0 {
+		evaluationError("Division by zero")
+	}
+	return MakeRat(l.Quo(l, r)), true
+}
+
+func modRat(l, r *big.Rat) (Value, bool) {
+	if r.Sign() == 0 {
+		evaluationError("Division by zero")
+	}
+	q := new(big.Rat).Quo(l, r)
+	t := new(big.Int).Quo(q.Num(), q.Denom())
+	return MakeRat(l.Sub(l, new(big.Rat).Mul(r, new(big.Rat).SetInt(t)))), true
+}
+
+func isSmall(l, r Int) bool {
+	return l.Big == nil && r.Big == nil
+}
+
+func addInt(l, r Int) (Value, bool) {
+	if isSmall(l, r) {
+		n := l.Data + r.Data
+		if (l.Data^n)&(r.Data^n) >= 0 {
+			return Int{Data: n}, true
+		}
+	}
+	return MakeInt(new(big.Int).Add(l.ToBig(), r.ToBig())), true
+}
+
+func subInt(l, r Int) (Value, bool) {
+	if isSmall(l, r) {
+		n := l.Data - r.Data
+		if (l.Data^r.Data)&(l.Data^n) >= 0 {
+			return Int{Data: n}, true
+		}
+	}
+	return MakeInt(new(big.Int).Sub(l.ToBig(), r.ToBig())), true
+}
+
+func mulInt(l, r Int) (Value, bool) {
+	if isSmall(l, r) {
+		if l.Data == 0 {
+			return Int{}, true
+		}
+		n := l.Data * r.Data
+		if n/l.Data == r.Data && !(l.Data == -1 && r.Data == math.MinInt64) {
+			return Int{Data: n}, true
+		}
+	}
+	return MakeInt(new(big.Int).Mul(l.ToBig(), r.ToBig())), true
+}
+
+func divInt(l, r Int) (Value, bool) {
+	if r.Big == nil && r.Data == 0 {
+		evaluationError("Division by zero")
+	}
+	if isSmall(l, r) && !(l.Data == math.MinInt64 && r.Data == -1) {
+		if l.Data%r.Data != 0 {
+			return nil, false
+		}
+		return Int{Data: l.Data / r.Data}, true
+	}
+	q, m := new(big.Int).QuoRem(l.ToBig(), r.ToBig(), new(big.Int))
+	if m.Sign() != 0 {
+		return nil, false
+	}
+	return MakeInt(q), true
+}
+
+func modInt(l, r Int) (Value, bool) {
+	if r.Big == nil && r.Data == 0 {
+		evaluationError("Division by zero")
+	}
+	if isSmall(l, r) {
+		return Int{Data: l.Data % r.Data}, true
+	}
+	return MakeInt(new(big.Int).Rem(l.ToBig(), r.ToBig())), true
+}
+
+func compareNums(l, r Value) int {
+	if a, ok := l.(Int); ok {
+		if b, ok := r.(Int); ok && isSmall(a, b) {
+			switch {
+			case a.Data < b.Data:
+				return -1
+			case a.Data > b.Data:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	if a, ok := ToRat(l); ok {
+		if b, ok := ToRat(r); ok {
+			return a.Cmp(b)
+		}
+	}
+	a, _ := ToFloat(l)
+	b, _ := ToFloat(r)
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func equalNums(l, r Value) bool {
+	_, lInexact := l.(Num)
+	_, rInexact := r.(Num)
+	if !lInexact && !rInexact {
+		return compareNums(l, r) == 0
+	}
+	a, _ := ToFloat(l)
+	b, _ := ToFloat(r)
+	return a == b
+}
+
+func toExact(v Value) (Value, bool) {
+	num, ok := v.(Num)
+	if !ok {
+		return v, true
+	}
+	if math.IsInf(num.Data, 0) || math.IsNaN(num.Data) {
+		return nil, false
+	}
+	return MakeRat(new(big.Rat).SetFloat64(num.Data)), true
+}
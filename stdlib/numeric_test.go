@@ -0,0 +1,58 @@
+package stdlib
+
+import "testing"
+
+func TestExactIntegers(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{`(* 99999999999 99999999999)`, `9999999999800000000001`},
+		{`(+ 9223372036854775807 1)`, `9223372036854775808`},
+		{`(- -9223372036854775808 1)`, `-9223372036854775809`},
+		{`(- (+ 9223372036854775807 1) 1)`, `9223372036854775807`},
+		{`(* -1 -9223372036854775808)`, `9223372036854775808`},
+		{`(+ 1 2.5)`, `3.5`},
+		{`(* 2 1.0)`, `2.0`},
+		{`(/ 6 3)`, `2`},
+		{`(% 7 3)`, `1`},
+		{`(% 7.5 2)`, `1.5`},
+		{`(= 1 1.0)`, `#t`},
+		{`(= 9007199254740993 9007199254740992)`, `#f`},
+		{`(< 9007199254740992 9007199254740993)`, `#t`},
+		{`(num->str 12345678901234567890)`, `"12345678901234567890"`},
+		{`(str->num "12345678901234567890")`, `12345678901234567890`},
+		{`(str->num "1e3")`, `1000.0`},
+		{`(vec (str->num "+1.") (str->num "-2.5E-3") (str->num "1_000"))`, `#(1.0 -0.0025 1000)`},
+		{`(vec (str->num "0x1p4") (str->num "0x10") (str->num "1p4") (str->num "0X1P-2"))`, `#(() () () ())`},
+		{`(vec (str->num "Inf") (str->num "infinity") (str->num "NaN") (str->num ".5") (str->num "1e"))`, `#(() () () () ())`},
+		{`(vec (str->num " 1") (str->num "1 ") (str->num "") (str->num "+") (str->num "١"))`, `#(() () () () ())`},
+		{`(/ 1 0)`, `error: test:1:2: Evaluation error: Division by zero`},
+		{`(% 1 0)`, `error: test:1:2: Evaluation error: Division by zero`},
+		{`(/ 0)`, `error: test:1:2: Evaluation error: Division by zero`},
+		{`(/ 1/2 0)`, `error: test:1:2: Evaluation error: Division by zero`},
+		{`(/ 1 0.0)`, `+inf.0`},
+		{`(% 1.0 0)`, `+nan.0`},
+	})
+}
+
+func TestRationals(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{`(/ 1 3)`, `1/3`},
+		{`(+ 1/3 2/3)`, `1`},
+		{`(* 2/4 3)`, `3/2`},
+		{`(- 1/2)`, `-1/2`},
+		{`(/ 3)`, `1/3`},
+		{`-6/4`, `-3/2`},
+		{`(vec (numerator 6/4) (denominator 6/4) (numerator 5) (denominator 5))`, `#(3 2 5 1)`},
+		{`(exact->inexact 1/4)`, `0.25`},
+		{`(inexact->exact 0.25)`, `1/4`},
+		{`(inexact->exact 2.0)`, `2`},
+		{`(+ 1/2 0.5)`, `1.0`},
+		{`(vec (= 1/2 0.5) (< 1/3 0.3334) (< 1/3 1/4))`, `#(#t #t #f)`},
+		{`(% 7/2 2)`, `3/2`},
+		{`(num->str 1/3)`, `"1/3"`},
+		{`(str->num "2/6")`, `1/3`},
+		{`(vec (str->num "010/3") (str->num "0x10/3") (str->num "0b1/1") (str->num "1/-2") (str->num "1/0"))`, `#(10/3 () () () ())`},
+		{`(vec (num? 1/3) (equal? 2/4 1/2))`, `#(#t #t)`},
+		{`(vec (numerator 0.5) (denominator 0.5))`, `#(1.0 2.0)`},
+		{`1/0`, `error: test:1:1: Invalid number: 1/0`},
+	})
+}
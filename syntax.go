@@ -10,11 +10,13 @@ func syntaxEnv() *Env {
 	env.Def("macro", syntax{syntaxMacro{}})
 	env.Def("builtin", syntax{syntaxBuiltin{}})
 	env.Def("quote", syntax{syntaxQuote{}})
+	env.Def("defrecord", syntax{syntaxDefrecord{}})
 	return env
 }
 
 type expandAll struct{}
 type noexpandFirst struct{}
+type noexpand struct{}
 
 func (expandAll) Expand(context *Context, args []Value) {
 	for i, arg := range args {
@@ -31,15 +33,24 @@ func (noexpandFirst) Expand(context *Context, args []Value) {
 	}
 }
 
+func (noexpand) Expand(context *Context, args []Value) {}
+
 type syntaxDef struct{ noexpandFirst }
 
 func (syntaxDef) Compile(compileEnv *Env, args []Value) Code {
 	if len(args) == 2 {
 		if sym, ok := args[0].(Sym); ok {
-			return append(compile(compileEnv, args[1]), def{sym.Data}, ldc{Nil{}})
+			code := compile(compileEnv, args[1])
+			if len(code) == 1 {
+				if f, ok := code[0].(ldf); ok {
+					f.name = sym.Data
+					code[0] = f
+				}
+			}
+			return append(code, def{sym.Data}, ldc{Nil{}})
 		}
 	}
-	panic(EvaluationError{"Syntax error: expected (def sym x)"})
+	panic(EvaluationError{Msg: "Syntax error: expected (def sym x)"})
 }
 
 type syntaxSet struct{ noexpandFirst }
@@ -47,10 +58,10 @@ type syntaxSet struct{ noexpandFirst }
 func (syntaxSet) Compile(compileEnv *Env, args []Value) Code {
 	if len(args) == 2 {
 		if sym, ok := args[0].(Sym); ok {
-			return append(compile(compileEnv, args[1]), set{sym.Data}, ldc{Nil{}})
+			return append(compile(compileEnv, args[1]), set{name: sym.Data}, ldc{Nil{}})
 		}
 	}
-	panic(EvaluationError{"Syntax error: expected (set! sym x)"})
+	panic(EvaluationError{Msg: "Syntax error: expected (set! sym x)"})
 }
 
 type syntaxBegin struct{ expandAll }
@@ -80,7 +91,7 @@ func (syntaxIf) Compile(compileEnv *Env, args []Value) Code {
 			})
 	}
 
-	panic(EvaluationError{"Syntax error: expected (if cond then else)"})
+	panic(EvaluationError{Msg: "Syntax error: expected (if cond then else)"})
 }
 
 type syntaxFun struct{ noexpandFirst }
@@ -90,10 +101,10 @@ func (syntaxFun) Compile(compileEnv *Env, args []Value) Code {
 		pat := buildPattern(args[0])
 		body := syntaxBegin{}.Compile(compileEnv, args[1:])
 		body = append(body, leave{})
-		return Code{ldf{pat, body}}
+		return Code{ldf{pattern: pat, code: body}}
 	}
 
-	panic(EvaluationError{"Syntax error: expected (fun pattern body...)"})
+	panic(EvaluationError{Msg: "Syntax error: expected (fun pattern body...)"})
 }
 
 type syntaxMacro struct{ noexpandFirst }
@@ -105,7 +116,7 @@ func (syntaxMacro) Compile(compileEnv *Env, args []Value) Code {
 		return Code{ldm{pat, body}}
 	}
 
-	panic(EvaluationError{"Syntax error: expected (macro pattern body...)"})
+	panic(EvaluationError{Msg: "Syntax error: expected (macro pattern body...)"})
 }
 
 type syntaxBuiltin struct{ noexpandFirst }
@@ -113,10 +124,10 @@ type syntaxBuiltin struct{ noexpandFirst }
 func (syntaxBuiltin) Compile(compileEnv *Env, args []Value) Code {
 	if len(args) == 1 {
 		if sym, ok := args[0].(Sym); ok {
-			return Code{ldb{sym.Data}}
+			return Code{ldb{name: sym.Data}}
 		}
 	}
-	panic(EvaluationError{"Syntax error: expected (builtin sym)"})
+	panic(EvaluationError{Msg: "Syntax error: expected (builtin sym)"})
 }
 
 type syntaxQuote struct{ noexpandFirst }
@@ -125,5 +136,5 @@ func (syntaxQuote) Compile(compileEnv *Env, args []Value) Code {
 	if len(args) == 1 {
 		return Code{ldc{args[0]}}
 	}
-	panic(EvaluationError{"Syntax error: expected (quote expr)"})
+	panic(EvaluationError{Msg: "Syntax error: expected (quote expr)"})
 }
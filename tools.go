@@ -1,7 +0,0 @@
-//go:build tools
-
-package tools
-
-import (
-	_ "golang.org/x/tools/cmd/goyacc"
-)
@@ -1,5 +1,7 @@
 package golisp
 
+import "strings"
+
 type Value interface {
 	Inspect() string
 }
@@ -16,6 +18,7 @@ type fun struct {
 	env     *Env
 	pattern pattern
 	code    Code
+	name    string
 }
 
 type builtin struct {
@@ -53,7 +56,32 @@ func (syntax) Inspect() string {
 }
 
 func (vec Vec) Inspect() string {
-	return Cons{Sym{"vec"}, List(vec.Payload...)}.Inspect()
+	return vec.inspect(&trail{})
+}
+
+func (vec Vec) inspect(t *trail) (s string) {
+	if len(vec.Payload) == 0 {
+		return "#()"
+	}
+	p, back := t.backref(vec.Payload)
+	if back {
+		return cycleMarker
+	}
+	t.within(p, func() {
+		items := make([]string, len(vec.Payload))
+		for i, item := range vec.Payload {
+			items[i] = inspect(item, t)
+		}
+		s = "#(" + strings.Join(items, " ") + ")"
+	})
+	return
+}
+
+func (f *fun) frame(pos *Pos) Frame {
+	if f == nil {
+		return Frame{Pos: pos}
+	}
+	return Frame{Name: f.name, Pattern: f.pattern.String(), Pos: pos}
 }
 
 func (fun) procValue()     {}
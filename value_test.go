@@ -0,0 +1,20 @@
+package golisp
+
+import "testing"
+
+func TestVecInspect(t *testing.T) {
+	tests := []struct {
+		vec  Vec
+		want string
+	}{
+		{Vec{}, "#()"},
+		{Vec{[]Value{Sym{"quote"}, Sym{"x"}}}, "#(quote x)"},
+		{Vec{[]Value{Sym{"unquote-splicing"}, Int{Data: 1}}}, "#(unquote-splicing 1)"},
+		{Vec{[]Value{Quote(Sym{"x"}), Nil{}}}, "#('x ())"},
+	}
+	for _, test := range tests {
+		if got := test.vec.Inspect(); got != test.want {
+			t.Errorf("Inspect() = %s, want %s", got, test.want)
+		}
+	}
+}
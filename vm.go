@@ -1,9 +1,30 @@
 package golisp
 
-import "fmt"
-
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// EvaluationError is also raised for the other errors during evaluation, which are kept in Err
+// and can be examined by errors.As and errors.Is
 type EvaluationError struct {
-	Msg string
+	Msg   string
+	Pos   *Pos
+	Trace []Frame
+	Err   error
+}
+
+type ResourceExhausted struct {
+	Resource string
+	Limit    int
+}
+
+type Frame struct {
+	Name    string
+	Pattern string
+	Pos     *Pos
 }
 
 type InternalError struct {
@@ -11,33 +32,77 @@ type InternalError struct {
 }
 
 func (e EvaluationError) Error() string {
+	if e.Pos != nil {
+		return e.Pos.String() + ": Evaluation error: " + e.Msg
+	}
 	return "Evaluation error: " + e.Msg
 }
 
+func (e EvaluationError) Unwrap() error {
+	return e.Err
+}
+
 func (e InternalError) Error() string {
 	return "Internal error: " + e.Msg
 }
 
+func (e ResourceExhausted) Error() string {
+	return "Resource exhausted: " + e.Resource + " exceeds the limit of " + strconv.Itoa(e.Limit)
+}
+
+func (frame Frame) String() string {
+	var s string
+	switch {
+	case frame.Name != "":
+		s = frame.Name + " " + frame.Pattern
+	case frame.Pattern != "":
+		s = "<fun> " + frame.Pattern
+	default:
+		s = "<toplevel>"
+	}
+	if frame.Pos != nil {
+		s += " at " + frame.Pos.String()
+	}
+	return s
+}
+
 type Context struct {
-	toplevel *Env
-	Builtins map[string]BuiltinImpl
+	toplevel     *Env
+	lastRead     located
+	Builtins     map[string]BuiltinImpl
+	ReadTable    map[string]ReaderMacro
+	Limits       Limits
+	Capabilities Capability
+	ctx          context.Context
+	running      int
+	insts        int
+}
+
+type Limits struct {
+	Insts int
+	Depth int
+	Stack int
 }
 
 type State struct {
 	Cont
 	Context *Context
+	inst    inst
 }
 
 type Cont struct {
 	stack []Value
 	env   *Env
 	code  Code
+	fun   *fun
 	dump  []dump
 }
 
 type dump struct {
 	env  *Env
 	code Code
+	fun  *fun
+	call inst
 }
 
 type SyntaxImpl interface {
@@ -50,27 +115,29 @@ type BuiltinImpl interface {
 }
 
 func compile(compileEnv *Env, expr Value) Code {
-	switch expr := expr.(type) {
+	switch e := expr.(type) {
 	case Sym:
-		return Code{ldv{expr.Data}}
+		return Code{ldv{name: e.Data}}
 
 	case Cons:
-		slice, ok := Slice(expr)
+		pos := e.pos
+		defer locateError(pos)
+
+		slice, ok := Slice(e)
 		if !ok {
-			panic(InternalError{"Improper list: " + expr.Inspect()})
+			panic(InternalError{"Improper list: " + e.Inspect()})
 		}
 
 		if syntax, ok := compileEnv.refer(slice[0]).(syntax); ok {
 			args := slice[1:]
-			return syntax.Compile(compileEnv, args)
+			return locateCode(syntax.Compile(compileEnv, args), pos)
 		}
 
 		code := Code{}
-		for _, v := range slice {
-			c := compile(compileEnv, v)
-			code = append(code, c...)
+		for cell, ok := e, true; ok; cell, ok = cell.Cdr.(Cons) {
+			code = append(code, locateCode(locateCode(compile(compileEnv, cell.Car), cell.pos), pos)...)
 		}
-		return append(code, app{len(slice) - 1})
+		return append(code, app{argc: len(slice) - 1, pos: pos})
 
 	default:
 		return Code{ldc{expr}}
@@ -78,6 +145,9 @@ func compile(compileEnv *Env, expr Value) Code {
 }
 
 func (state *State) Push(value Value) {
+	if limit := state.Context.Limits.Stack; limit > 0 && len(state.stack) >= limit {
+		panic(ResourceExhausted{"stack size", limit})
+	}
 	state.stack = append(state.stack, value)
 }
 
@@ -90,17 +160,21 @@ func (state *State) pop() Value {
 	return ret
 }
 
-func (state *State) enter(env *Env, code Code) {
+func (state *State) enter(env *Env, code Code, call inst, f *fun) {
 	skipThisFrame := false
 	if len(state.code) == 1 {
 		_, skipThisFrame = state.code[0].(leave)
 	}
 
 	if !skipThisFrame {
-		state.dump = append(state.dump, dump{state.env, state.code})
+		if limit := state.Context.Limits.Depth; limit > 0 && len(state.dump) >= limit {
+			panic(ResourceExhausted{"dump depth", limit})
+		}
+		state.dump = append(state.dump, dump{state.env, state.code, state.fun, call})
 	}
 	state.env = env
 	state.code = code
+	state.fun = f
 }
 
 func (state *State) leave() {
@@ -111,19 +185,32 @@ func (state *State) leave() {
 	state.dump = state.dump[:len(state.dump)-1]
 	state.env = dump.env
 	state.code = dump.code
+	state.fun = dump.fun
+}
+
+func (state *State) backtrace() []Frame {
+	trace := []Frame{state.fun.frame(instPos(state.inst))}
+	for i := len(state.dump) - 1; i >= 0; i-- {
+		dump := state.dump[i]
+		if dump.call != nil {
+			trace = append(trace, dump.fun.frame(instPos(dump.call)))
+		}
+	}
+	return trace
 }
 
 func (state *State) Apply(f Value, args ...Value) {
 	switch f := f.(type) {
 	case fun:
-		state.enter(NewEnv(f.env), f.code)
-		f.pattern.bind(args, state.env)
+		env := NewEnv(f.env)
+		f.pattern.bind(args, env)
+		state.enter(env, f.code, state.inst, &f)
 
 	case builtin:
 		f.Run(state, args)
 
 	default:
-		panic(EvaluationError{"Cannot call: " + f.Inspect()})
+		panic(EvaluationError{Msg: "Cannot call: " + f.Inspect()})
 	}
 }
 
@@ -143,6 +230,7 @@ func (dest *Cont) copy(src Cont) {
 	}
 	dest.env = src.env
 	dest.code = src.code
+	dest.fun = src.fun
 	copied = copy(dest.dump, src.dump)
 	if copied < len(src.dump) {
 		dest.dump = append(dest.dump, src.dump[copied:]...)
@@ -159,7 +247,7 @@ func (state *State) CaptureCont() Value {
 
 func (cont Cont) Run(state *State, args []Value) {
 	if len(args) > 1 {
-		panic(EvaluationError{"Multiple values are not implemented"})
+		panic(EvaluationError{Msg: "Multiple values are not implemented"})
 	}
 
 	state.copy(cont)
@@ -180,17 +268,13 @@ func (state *State) runInst(i inst) {
 		state.Push(state.env.Get(i.name))
 
 	case ldf:
-		state.Push(fun{state.env, i.pattern, i.code})
+		state.Push(fun{state.env, i.pattern, i.code, i.name})
 
 	case ldm:
 		state.Push(macro{state.env, i.pattern, i.code})
 
 	case ldb:
-		impl, ok := state.Context.Builtins[i.name]
-		if !ok {
-			panic(EvaluationError{"Unsupported builtin: " + i.name})
-		}
-		state.Push(builtin{impl})
+		state.Push(builtin{state.Context.builtin(i.name)})
 
 	case sel:
 		var branchCode Code
@@ -199,7 +283,7 @@ func (state *State) runInst(i inst) {
 		} else {
 			branchCode = i.b
 		}
-		state.enter(NewEnv(state.env), branchCode)
+		state.enter(NewEnv(state.env), branchCode, nil, state.fun)
 
 	case app:
 		args := make([]Value, i.argc)
@@ -226,28 +310,78 @@ func (state *State) runInst(i inst) {
 }
 
 func (state *State) run() Value {
+	defer state.locateError()
+
+	var done <-chan struct{}
+	if state.Context.ctx != nil {
+		done = state.Context.ctx.Done()
+	}
+
 	for len(state.code) != 0 {
-		inst := state.code[0]
+		select {
+		case <-done:
+			// Located at the instruction to be executed next
+			state.inst = state.code[0]
+			panic(state.Context.ctx.Err())
+		default:
+		}
+
+		state.Context.insts++
+		if limit := state.Context.Limits.Insts; limit > 0 && state.Context.insts > limit {
+			panic(ResourceExhausted{"instruction count", limit})
+		}
+
+		state.inst = state.code[0]
 		state.code = state.code[1:]
-		state.runInst(inst)
+		state.runInst(state.inst)
 	}
 	return state.pop()
 }
 
+func (state *State) locateError() {
+	if r := recover(); r != nil {
+		r = withPos(r, instPos(state.inst))
+		if e, ok := r.(EvaluationError); ok && e.Trace == nil {
+			e.Trace = state.backtrace()
+			r = e
+		}
+		panic(r)
+	}
+}
+
 func (context *Context) exec(env *Env, code Code) Value {
-	state := State{Cont{env: env, code: code}, context}
+	state := State{Cont: Cont{env: env, code: code}, Context: context}
+	return state.run()
+}
+
+func (context *Context) apply(f Value, args []Value) Value {
+	state := State{Context: context}
+	state.Apply(f, args...)
 	return state.run()
 }
 
 func (context *Context) macroExpand(recurse bool, expr Value) Value {
 	slice, ok := Slice(expr)
 	if ok && len(slice) != 0 {
+		pos := expr.(Cons).pos
+		defer locateError(pos)
+
 		args := slice[1:]
 		switch m := context.toplevel.refer(slice[0]).(type) {
 		case macro:
+			if sym, ok := slice[0].(Sym); ok && sym.Data == "quasiquote" {
+				for i, arg := range args {
+					args[i] = context.quasiquoteVec(arg, 1)
+				}
+			}
 			env := NewEnv(m.env)
 			m.pattern.bind(args, env)
 			expr = context.exec(env, m.code)
+			// The expansion may be shared by every call site, as a quoted constant in the macro is
+			if cons, ok := expr.(Cons); ok && pos != nil {
+				cons.pos = pos
+				expr = cons
+			}
 			if !recurse {
 				return expr
 			}
@@ -258,7 +392,7 @@ func (context *Context) macroExpand(recurse bool, expr Value) Value {
 				return expr
 			}
 			m.Expand(context, args)
-			return List(slice...)
+			return context.relist(expr, slice)
 		}
 	}
 
@@ -273,9 +407,109 @@ func (context *Context) macroExpandChildren(expr Value) Value {
 	if !ok {
 		return expr
 	}
-	return Cons{
-		Car: context.macroExpand(true, cons.Car),
-		Cdr: context.macroExpandChildren(cons.Cdr),
+	return Cons{context.macroExpand(true, cons.Car), context.macroExpandChildren(cons.Cdr), cons.pos}
+}
+
+// Vector templates are rewritten into list templates since quasiquote only traverses conses:
+// `#(a ,b) => `,((builtin list->vec) `(a ,b)). The quasiquote macro is defined in Lisp, and the
+// symbol is the one the reader produces for a backquote.
+func (context *Context) quasiquoteVec(expr Value, depth int) Value {
+	switch e := expr.(type) {
+	case Vec:
+		payload := make([]Value, len(e.Payload))
+		unquoted := false
+		for i, item := range e.Payload {
+			payload[i] = context.quasiquoteVec(item, depth)
+			unquoted = unquoted || containsUnquote(payload[i], depth)
+		}
+		if !unquoted {
+			return Vec{Payload: payload}
+		}
+		return Unquote(List(List(Sym{"builtin"}, Sym{"list->vec"}), Quasiquote(List(payload...))))
+
+	case Cons:
+		if sym, ok := e.Car.(Sym); ok {
+			switch sym.Data {
+			case "quasiquote":
+				depth++
+			case "unquote", "unquote-splicing":
+				depth--
+			}
+		}
+		if depth == 0 {
+			return expr
+		}
+		return Cons{context.quasiquoteVec(e.Car, depth), context.quasiquoteVec(e.Cdr, depth), e.pos}
+
+	default:
+		return expr
+	}
+}
+
+// list->vec is registered to every context, since rewritten templates refer to it
+type listToVec struct{}
+
+func (listToVec) Run(state *State, args []Value) {
+	if len(args) != 1 {
+		panic(EvaluationError{Msg: "list->vec takes one argument"})
+	}
+	items, ok := Slice(args[0])
+	if !ok {
+		panic(EvaluationError{Msg: "list->vec: Expected list but got " + args[0].Inspect()})
+	}
+	state.Push(Vec{Payload: items})
+}
+
+func containsUnquote(expr Value, depth int) bool {
+	cons, ok := expr.(Cons)
+	if !ok {
+		return false
+	}
+	if sym, ok := cons.Car.(Sym); ok {
+		switch sym.Data {
+		case "quasiquote":
+			depth++
+		case "unquote", "unquote-splicing":
+			if depth == 1 {
+				return true
+			}
+			depth--
+		}
+	}
+	return containsUnquote(cons.Car, depth) || containsUnquote(cons.Cdr, depth)
+}
+
+func (context *Context) relist(expr Value, values []Value) Value {
+	cons, ok := expr.(Cons)
+	if !ok || len(values) == 0 {
+		return Nil{}
+	}
+	return Cons{values[0], context.relist(cons.Cdr, values[1:]), cons.pos}
+}
+
+func withPos(r interface{}, pos *Pos) interface{} {
+	switch e := r.(type) {
+	case EvaluationError:
+		if e.Pos == nil {
+			e.Pos = pos
+		}
+		return e
+	case UndefinedVariable, PermissionDenied, ResourceExhausted:
+		err := r.(error)
+		return EvaluationError{Msg: err.Error(), Pos: pos, Err: err}
+	case error:
+		if errors.Is(e, context.Canceled) || errors.Is(e, context.DeadlineExceeded) {
+			return EvaluationError{Msg: e.Error(), Pos: pos, Err: e}
+		}
+		return r
+	default:
+		return r
+	}
+}
+
+func locateError(pos *Pos) {
+	if r := recover(); r != nil {
+		panic(withPos(r, pos))
 	}
 }
 
@@ -291,11 +525,27 @@ func recoverContext(err *error) {
 
 func NewContext() *Context {
 	return &Context{
-		toplevel: NewEnv(syntaxEnv()),
-		Builtins: map[string]BuiltinImpl{},
+		toplevel:     NewEnv(syntaxEnv()),
+		Builtins:     map[string]BuiltinImpl{"list->vec": listToVec{}},
+		ReadTable:    map[string]ReaderMacro{},
+		Capabilities: AllCapabilities,
 	}
 }
 
+func (context *Context) withContext(ctx context.Context) func() {
+	prev := context.ctx
+	context.ctx = ctx
+	return func() { context.ctx = prev }
+}
+
+func (context *Context) begin() func() {
+	if context.running == 0 {
+		context.insts = 0
+	}
+	context.running++
+	return func() { context.running-- }
+}
+
 func (context *Context) Compile(expr Value) (result Code, err error) {
 	defer recoverContext(&err)
 	result = compile(context.toplevel, expr)
@@ -304,14 +554,53 @@ func (context *Context) Compile(expr Value) (result Code, err error) {
 
 func (context *Context) MacroExpand(recurse bool, expr Value) (result Value, err error) {
 	defer recoverContext(&err)
+	defer context.begin()()
 	result = context.macroExpand(recurse, expr)
 	return
 }
 
 func (context *Context) Eval(expr Value) (result Value, err error) {
 	defer recoverContext(&err)
+	defer context.begin()()
+	pos := context.toplevelPos(expr)
 	expr = context.macroExpand(true, expr)
-	code := compile(context.toplevel, expr)
+	code := locateCode(compile(context.toplevel, expr), pos)
 	result = context.exec(context.toplevel, code)
 	return
 }
+
+// A toplevel expression is located before macro expansion, which may discard the position. A symbol
+// has no cons to hold its position, and is located by the reader of the context that read it last.
+func (context *Context) toplevelPos(expr Value) *Pos {
+	switch e := expr.(type) {
+	case Cons:
+		return e.pos
+	case Sym:
+		if read, ok := context.lastRead.value.(Sym); ok && read == e {
+			return context.lastRead.pos
+		}
+	}
+	return nil
+}
+
+func (context *Context) Call(proc Value, args ...Value) (result Value, err error) {
+	defer recoverContext(&err)
+	defer context.begin()()
+	result = context.apply(proc, args)
+	return
+}
+
+func (context *Context) MacroExpandContext(ctx context.Context, recurse bool, expr Value) (Value, error) {
+	defer context.withContext(ctx)()
+	return context.MacroExpand(recurse, expr)
+}
+
+func (context *Context) EvalContext(ctx context.Context, expr Value) (Value, error) {
+	defer context.withContext(ctx)()
+	return context.Eval(expr)
+}
+
+func (context *Context) CallContext(ctx context.Context, proc Value, args ...Value) (Value, error) {
+	defer context.withContext(ctx)()
+	return context.Call(proc, args...)
+}
@@ -0,0 +1,327 @@
+package golisp
+
+import (
+	"bufio"
+	stdcontext "context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func evalString(context *Context, file, src string) (result Value, err error) {
+	reader := context.NewReader(file, bufio.NewReader(strings.NewReader(src)))
+	for {
+		expr, err := reader.ReadValue()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err == nil {
+			result, err = context.Eval(expr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func TestQuasiquoteVec(t *testing.T) {
+	// A context without any of the standard builtins, with a quasiquote that handles unquote only
+	context := NewContext()
+	context.RegisterFunc("cons", func(a, b Value) Value { return Cons{Car: a, Cdr: b} })
+	var qq func(v Value) Value
+	qq = func(v Value) Value {
+		cons, ok := v.(Cons)
+		if !ok {
+			return Quote(v)
+		}
+		if sym, ok := cons.Car.(Sym); ok && sym.Data == "unquote" {
+			return cons.Cdr.(Cons).Car
+		}
+		return List(List(Sym{"builtin"}, Sym{"cons"}), qq(cons.Car), qq(cons.Cdr))
+	}
+	context.RegisterFunc("qq", qq)
+	if _, err := evalString(context, "", "(def quasiquote (macro (x) ((builtin qq) x))) (def x 2)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		src, want string
+	}{
+		{"`#(1 ,x)", "#(1 2)"},
+		{"`(a #(b #(,x)))", "(a #(b #(2)))"},
+		{"`#(1 x)", "#(1 x)"},
+		{"`#()", "#()"},
+	}
+	for _, test := range tests {
+		result, err := evalString(context, "", test.src)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+		} else if got := result.Inspect(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.src, got, test.want)
+		}
+	}
+
+	// The expansion refers to list->vec by name, so that it can be printed and read again
+	expr, err := newStringReader("`#(1 ,x)").ReadValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expanded, err := context.MacroExpand(false, expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := expanded.Inspect()
+	if want := "((builtin list->vec) `(1 ,x))"; src != want {
+		t.Errorf("got %s, want %s", src, want)
+	}
+	reread, err := newStringReader(src).ReadValue()
+	if err != nil || !Equal(reread, expanded) {
+		t.Errorf("%s is read as %v, %v", src, reread, err)
+	}
+	if result, err := context.Eval(reread); err != nil || result.Inspect() != "#(1 2)" {
+		t.Errorf("%s: got %v, %v", src, result, err)
+	}
+	if _, err := evalString(context, "", "((builtin list->vec) 1)"); err == nil || err.Error() != "1:2: Evaluation error: list->vec: Expected list but got 1" {
+		t.Errorf("got %v", err)
+	}
+}
+
+type callCC struct{}
+
+func (callCC) Run(state *State, args []Value) {
+	state.Apply(args[0], state.CaptureCont())
+}
+
+func TestCall(t *testing.T) {
+	context := NewContext()
+	context.Builtins["call/cc"] = callCC{}
+	context.RegisterFunc("add", func(a, b int) int { return a + b })
+	context.RegisterFunc("map", func(f Value, xs []Value) ([]Value, error) {
+		for i, x := range xs {
+			y, err := context.Call(f, x)
+			if err != nil {
+				return nil, err
+			}
+			xs[i] = y
+		}
+		return xs, nil
+	})
+	src := `
+(def add (builtin add))
+(def call/cc (builtin call/cc))
+(def k ())
+(def inc (fun (x) (add x 1)))
+(def early (fun (x) (call/cc (fun (return) (return x) 0))))
+(def resume (fun (x) (add x (call/cc (fun (c) (set! k c) 1)))))
+(def fail (fun () (undefined)))
+(def both (fun xs ((builtin map) inc xs)))
+`
+	if _, err := evalString(context, "test", src); err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(name string) Value {
+		v, err := context.Eval(Sym{name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		proc Value
+		args []Value
+		want string
+	}{
+		{lookup("inc"), []Value{Int{Data: 1}}, "2"},
+		{lookup("add"), []Value{Int{Data: 1}, Int{Data: 2}}, "3"},
+		{lookup("early"), []Value{Str{"x"}}, `"x"`},
+		{lookup("resume"), []Value{Int{Data: 10}}, "11"},
+		{lookup("both"), []Value{Int{Data: 1}, Int{Data: 2}}, "(#t 2 3)"},
+		{lookup("inc"), nil, "error: Evaluation error: This function takes at least 1 arguments"},
+		{lookup("fail"), nil, "error: test:8:20: Evaluation error: Undefined variable: undefined"},
+		{Int{Data: 1}, nil, "error: Evaluation error: Cannot call: 1"},
+	}
+	for _, test := range tests {
+		result, err := context.Call(test.proc, test.args...)
+		var got string
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = result.Inspect()
+		}
+		if got != test.want {
+			t.Errorf("%s applied to %s\n got: %s\nwant: %s", test.proc.Inspect(), List(test.args...).Inspect(), got, test.want)
+		}
+	}
+
+	// The continuation captured by a previous call resumes its rest of the computation
+	if result, err := context.Call(lookup("k"), Int{Data: 5}); err != nil || result.Inspect() != "15" {
+		t.Errorf("got %v, %v", result, err)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	context := NewContext()
+	if _, err := evalString(context, "test", "(def loop (fun () (loop)))"); err != nil {
+		t.Fatal(err)
+	}
+	loop, _ := context.NewReader("test", bufio.NewReader(strings.NewReader("\n(loop)"))).ReadValue()
+	// The error is located where the evaluation is stopped, and has a trace
+	check := func(err, want error, pos string) {
+		t.Helper()
+		if e, ok := err.(EvaluationError); !ok || !errors.Is(err, want) || e.Pos == nil || e.Pos.String() != pos || len(e.Trace) == 0 {
+			t.Errorf("got %#v, want %v at %s", err, want, pos)
+		}
+	}
+
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := context.EvalContext(ctx, loop)
+	check(err, stdcontext.DeadlineExceeded, "test:1:20")
+
+	ctx, cancel = stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	_, err = context.EvalContext(ctx, loop)
+	check(err, stdcontext.Canceled, "test:2:2")
+	proc, _ := context.Eval(Sym{"loop"})
+	_, err = context.CallContext(ctx, proc)
+	check(err, stdcontext.Canceled, "test:1:20")
+
+	// The context stays usable after a cancellation
+	if result, err := context.Eval(Quote(Sym{"ok"})); err != nil || result.Inspect() != "ok" {
+		t.Errorf("got %v, %v", result, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var nested strings.Builder
+	for i := 0; i < 100; i++ {
+		nested.WriteString("(cons 1 ")
+	}
+	nested.WriteString("()" + strings.Repeat(")", 100))
+
+	tests := []struct {
+		limits Limits
+		src    string
+		want   ResourceExhausted
+		msg    string
+	}{
+		{Limits{Insts: 1000}, "(loop)", ResourceExhausted{"instruction count", 1000}, "test:1:46: Evaluation error: Resource exhausted: instruction count exceeds the limit of 1000"},
+		{Limits{Depth: 50}, "(deep)", ResourceExhausted{"dump depth", 50}, "test:1:81: Evaluation error: Resource exhausted: dump depth exceeds the limit of 50"},
+		{Limits{Stack: 50}, nested.String(), ResourceExhausted{"stack size", 50}, "test:1:202: Evaluation error: Resource exhausted: stack size exceeds the limit of 50"},
+	}
+	for _, test := range tests {
+		context := NewContext()
+		context.RegisterFunc("cons", func(a, b Value) Value { return Cons{Car: a, Cdr: b} })
+		if _, err := evalString(context, "test", "(def cons (builtin cons)) (def loop (fun () (loop))) (def deep (fun () (cons 1 (deep))))"); err != nil {
+			t.Fatal(err)
+		}
+		context.Limits = test.limits
+		_, err := evalString(context, "test", test.src)
+		var e ResourceExhausted
+		if !errors.As(err, &e) || e != test.want || err.Error() != test.msg {
+			t.Errorf("%s: got %v, want %v", test.src, err, test.msg)
+		}
+		// Limits apply to each Eval
+		if _, err := evalString(context, "test", "(cons 1 2)"); err != nil {
+			t.Errorf("got %v after %v", err, test.want)
+		}
+		context.Limits = Limits{}
+		if _, err := evalString(context, "test", nested.String()); err != nil {
+			t.Errorf("got %v without limits", err)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"\n  undefined-toplevel", "test:2:3: Evaluation error: Undefined variable: undefined-toplevel"},
+		{"(if #t\n  (undefined-fn 1) 2)", "test:2:4: Evaluation error: Undefined variable: undefined-fn"},
+		{"(def f (fun (x) (g x)))\n(f 1)", "test:1:18: Evaluation error: Undefined variable: g"},
+		{"(1 2)", "test:1:2: Evaluation error: Cannot call: 1"},
+		{"(def id (macro (x) x))\n(id\n  undefined)", "test:2:2: Evaluation error: Undefined variable: undefined"},
+		{"(def m (macro () '(undefined-fn)))\n(begin\n  (m))", "test:3:4: Evaluation error: Undefined variable: undefined-fn"},
+		{"(def m (macro () '(begin 1 (2))))\n  (m)", "test:1:29: Evaluation error: Cannot call: 2"},
+		{"(def m (macro () '(undefined-fn)))\n(if #f\n  (m)\n  (m))", "test:4:4: Evaluation error: Undefined variable: undefined-fn"},
+		{"(fun)", "test:1:2: Evaluation error: Syntax error: expected (fun pattern body...)"},
+	}
+	for _, test := range tests {
+		_, err := evalString(NewContext(), "test", test.src)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q\n got: %v\nwant: %s", test.src, err, test.want)
+		}
+	}
+}
+
+func TestErrorTrace(t *testing.T) {
+	src := "(def f (fun (x) (g x) x))\n(def g (fun (y) (undefined y)))\n(f 1)"
+	_, err := evalString(NewContext(), "test", src)
+	e, ok := err.(EvaluationError)
+	if !ok {
+		t.Fatalf("got %v", err)
+	}
+	var trace []string
+	for _, frame := range e.Trace {
+		trace = append(trace, frame.String())
+	}
+	want := []string{"g (y) at test:2:18", "f (x) at test:1:18", "<toplevel> at test:3:2"}
+	if len(trace) != len(want) {
+		t.Fatalf("got %q, want %q", trace, want)
+	}
+	for i := range want {
+		if trace[i] != want[i] {
+			t.Errorf("frame %d: got %s, want %s", i, trace[i], want[i])
+		}
+	}
+}
+
+func TestDefrecord(t *testing.T) {
+	context := NewContext()
+	context.RegisterFunc("equal?", Equal)
+	src := "(defrecord point (x y)) (defrecord empty ()) (def p (make-point 1 2))"
+	if _, err := evalString(context, "test", src); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		src, want string
+	}{
+		{"p", "#<point x=1 y=2>"},
+		{"point", "<record-type point>"},
+		{"(make-empty)", "#<empty>"},
+		{"(point? p)", "#t"},
+		{"(point? (make-empty))", "#f"},
+		{"(point? '(1 2))", "#f"},
+		{"(point-x p)", "1"},
+		{"(point-y p)", "2"},
+		{"(set-point-x! p 'a)", "()"},
+		{"p", "#<point x=a y=2>"},
+		{"(set-point-y! p p) p", "#<point x=a y=#<cycle>>"},
+		{"(set-point-y! p 2) ((builtin equal?) p (make-point 'a 2))", "#t"},
+		{"((builtin equal?) p (make-point 'a 3))", "#f"},
+		{"((builtin equal?) (make-empty) (make-empty))", "#t"},
+		{"(defrecord other (x y)) ((builtin equal?) (make-point 1 2) (make-other 1 2))", "#f"},
+		{"(make-point 1)", "error: test:1:2: Evaluation error: make-point takes 2 fields"},
+		{"(point-x (make-empty))", "error: test:1:2: Evaluation error: point-x: Expected point but got #<empty>"},
+		{"(set-point-y! p)", "error: test:1:2: Evaluation error: set-point-y! takes two arguments"},
+		{"(point? p p)", "error: test:1:2: Evaluation error: point? takes one argument"},
+		{"(defrecord point (x 1))", "error: test:1:2: Evaluation error: Syntax error: expected field name but got 1"},
+		{"(defrecord point)", "error: test:1:2: Evaluation error: Syntax error: expected (defrecord name (field...))"},
+	}
+	for _, test := range tests {
+		result, err := evalString(context, "test", test.src)
+		var got string
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = result.Inspect()
+		}
+		if got != test.want {
+			t.Errorf("%s\n got: %s\nwant: %s", test.src, got, test.want)
+		}
+	}
+}